@@ -1,17 +1,23 @@
 package main
 
 import (
+	"crypto/rand"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/dunkykorZhik/social/internal/api"
 	"github.com/dunkykorZhik/social/internal/auth"
+	"github.com/dunkykorZhik/social/internal/blob"
 	"github.com/dunkykorZhik/social/internal/db"
 	env "github.com/dunkykorZhik/social/internal/env"
 	"github.com/dunkykorZhik/social/internal/mailer"
+	"github.com/dunkykorZhik/social/internal/oidc"
 	"github.com/dunkykorZhik/social/internal/rateLimiter"
 	"github.com/dunkykorZhik/social/internal/storage"
 	"github.com/dunkykorZhik/social/internal/storage/cache"
+	"github.com/dunkykorZhik/social/internal/unfurl"
 	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -72,9 +78,12 @@ func main() {
 				Password: env.GetString("BASIC_AUTH_PASS", "admin"),
 			},
 			Token: api.TokenConfig{
-				Secret: env.GetString("AUTH_TOKEN_SECRET", "example"),
-				Exp:    time.Hour * 24 * 3, // 3 days
-				Iss:    "gophersocial",
+				Secret:     env.GetString("AUTH_TOKEN_SECRET", ""),
+				Keys:       env.GetString("AUTH_TOKEN_KEYS", ""),
+				KeyGrace:   time.Hour,
+				Exp:        time.Minute * 15,
+				RefreshExp: time.Hour * 24 * 30, // 30 days
+				Iss:        "gophersocial",
 			},
 		},
 		RedisConfig: api.RedisConfig{
@@ -88,6 +97,49 @@ func main() {
 			TimeFrame:    time.Second * 5,
 			Enabled:      env.GetBool("RATE_LIMITER_ENABLED", true),
 		},
+		PostConfig: api.PostConfig{
+			TrashRetention:  time.Hour * 24 * 30, // 30 days
+			PurgeInterval:   time.Hour,
+			PublishInterval: time.Second * 30,
+			MaxPinned:       env.GetInt("POST_MAX_PINNED", 3),
+		},
+		MediaConfig: api.MediaConfig{
+			Dir:            env.GetString("MEDIA_DIR", "./uploads"),
+			BaseURL:        env.GetString("MEDIA_BASE_URL", "http://localhost:4040/v1/media"),
+			MaxFileSize:    int64(env.GetInt("MEDIA_MAX_FILE_SIZE", 5<<20)), // 5MB
+			MaxAttachments: 4,
+			URLSecret:      env.GetString("MEDIA_URL_SECRET", ""),
+			URLExp:         time.Hour,
+		},
+		ContentConfig: api.ContentConfig{
+			PlainMaxLength:    env.GetInt("CONTENT_PLAIN_MAX_LENGTH", 200),
+			MarkdownMaxLength: env.GetInt("CONTENT_MARKDOWN_MAX_LENGTH", 2000),
+		},
+		TagConfig: api.TagConfig{
+			TrendingWindow: time.Hour * 24,
+		},
+		PollConfig: api.PollConfig{
+			HideResultsUntilVoted: env.GetBool("POLL_HIDE_RESULTS_UNTIL_VOTED", true),
+		},
+		ProfileConfig: api.ProfileConfig{
+			UsernameCooldown: time.Hour * 24 * 30, // 30 days
+		},
+		OIDCConfig: api.OIDCConfig{
+			StateExp: time.Minute * 10,
+		},
+		TwoFactorConfig: api.TwoFactorConfig{
+			Issuer:       env.GetString("TOTP_ISSUER", "GopherSocial"),
+			ChallengeExp: time.Minute * 5,
+			MaxAttempts:  5,
+			Lockout:      time.Minute * 15,
+		},
+		PreviewConfig: api.PreviewConfig{
+			Enabled:     env.GetBool("LINK_PREVIEW_ENABLED", true),
+			Timeout:     time.Second * 5,
+			MaxBodySize: int64(env.GetInt("LINK_PREVIEW_MAX_BODY_SIZE", 512<<10)), // 512KB
+			CacheTTL:    time.Hour * 24,
+			QueueSize:   100,
+		},
 	}
 
 	db, err := db.New(cfg.Db.Addr, cfg.Db.MaxOpenConns, cfg.Db.MaxIdleConns, cfg.Db.MaxIdleTime)
@@ -107,7 +159,27 @@ func main() {
 
 	}
 
-	auth := auth.NewAuthenticator(cfg.AuthConfig.Token.Secret, cfg.AuthConfig.Token.Iss, cfg.AuthConfig.Token.Iss)
+	var authenticator auth.Authenticator
+	if cfg.AuthConfig.Token.Keys != "" {
+		keys, err := auth.LoadKeys(cfg.AuthConfig.Token.Keys)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		authenticator, err = auth.NewKeyAuthenticator(keys, cfg.AuthConfig.Token.KeyGrace, cfg.AuthConfig.Token.Iss, cfg.AuthConfig.Token.Iss)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	} else {
+		// The shared secret is a development convenience, deployments sign with the keys of AUTH_TOKEN_KEYS.
+		if cfg.Env != "development" {
+			logger.Fatalf("AUTH_TOKEN_KEYS is required when ENV is %s", cfg.Env)
+		}
+		if cfg.AuthConfig.Token.Secret == "" {
+			logger.Fatal("set AUTH_TOKEN_KEYS or, in development, AUTH_TOKEN_SECRET")
+		}
+		logger.Warnw("AUTH_TOKEN_KEYS is not set, signing tokens with the shared secret")
+		authenticator = auth.NewAuthenticator(cfg.AuthConfig.Token.Secret, cfg.AuthConfig.Token.Iss, cfg.AuthConfig.Token.Iss)
+	}
 
 	var rdb *redis.Client
 	if cfg.RedisConfig.Enabled {
@@ -118,6 +190,56 @@ func main() {
 
 	cacheStr := cache.NewRedisStorage(rdb)
 
+	if cfg.MediaConfig.URLSecret == "" {
+		if cfg.Env != "development" {
+			logger.Fatalf("MEDIA_URL_SECRET is required when ENV is %s", cfg.Env)
+		}
+		// The media links of a development server stop working when it restarts.
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Fatal(err)
+		}
+		cfg.MediaConfig.URLSecret = string(secret)
+		logger.Warnw("MEDIA_URL_SECRET is not set, signing media links with a random secret")
+	}
+
+	blobStore, err := blob.NewLocalStore(cfg.MediaConfig.Dir, cfg.MediaConfig.BaseURL)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	var unfurler *unfurl.Unfurler
+	if cfg.PreviewConfig.Enabled {
+		unfurler = unfurl.New(unfurl.Config{
+			Timeout:      cfg.PreviewConfig.Timeout,
+			MaxBodySize:  cfg.PreviewConfig.MaxBodySize,
+			MaxRedirects: 3,
+			UserAgent:    "social-link-preview",
+		})
+	}
+
+	providers := make(map[string]*oidc.Provider)
+	for _, name := range strings.Split(env.GetString("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		// The callback is served over TLS, the browser only sends the Secure state cookie there.
+		pc := oidc.Config{
+			Name:         name,
+			Issuer:       env.GetString(prefix+"ISSUER", ""),
+			ClientID:     env.GetString(prefix+"CLIENT_ID", ""),
+			ClientSecret: env.GetString(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  env.GetString(prefix+"REDIRECT_URL", fmt.Sprintf("https://%s/v1/authentication/oidc/%s/callback", cfg.ExternalAddr, name)),
+		}
+		if pc.Issuer == "" || pc.ClientID == "" {
+			logger.Fatalf("OIDC provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		cfg.OIDCConfig.Providers = append(cfg.OIDCConfig.Providers, pc)
+		providers[name] = oidc.New(pc, nil)
+	}
+
 	rateL := rateLimiter.NewRateLimiter(cfg.RateLimiterConfig.RequestPerTF, cfg.RateLimiterConfig.TimeFrame)
 	app := &api.Application{
 		Config:       cfg,
@@ -125,8 +247,11 @@ func main() {
 		CacheStorage: cacheStr,
 		Logger:       logger,
 		Mailer:       mailer,
-		Auth:         auth,
+		Auth:         authenticator,
 		RateLimiter:  rateL,
+		Blob:         blobStore,
+		Unfurler:     unfurler,
+		OIDC:         providers,
 	}
 
 	mux := app.Mount()
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_comments_parent_id;
+
+ALTER TABLE
+  IF EXISTS comments DROP COLUMN IF EXISTS depth;
+
+ALTER TABLE
+  IF EXISTS comments DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL,
+  version INT NOT NULL,
+  title text NOT NULL,
+  content text NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  UNIQUE (post_id, version),
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
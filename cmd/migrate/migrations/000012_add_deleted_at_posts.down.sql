@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE
+  IF EXISTS posts
+ADD
+  COLUMN deleted_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
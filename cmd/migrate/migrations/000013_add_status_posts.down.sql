@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_posts_publish_at;
+
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS publish_at;
+
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,11 @@
+ALTER TABLE
+  IF EXISTS posts
+ADD
+  COLUMN status varchar(20) NOT NULL DEFAULT 'published';
+
+ALTER TABLE
+  IF EXISTS posts
+ADD
+  COLUMN publish_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_publish_at ON posts (publish_at) WHERE status = 'scheduled';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS post_attachments (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL,
+  storage_key text NOT NULL UNIQUE,
+  filename text NOT NULL,
+  content_type varchar(255) NOT NULL,
+  size bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_attachments_post_id ON post_attachments (post_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS post_attachment_variants;
+
+ALTER TABLE
+  IF EXISTS post_attachments DROP COLUMN IF EXISTS height;
+
+ALTER TABLE
+  IF EXISTS post_attachments DROP COLUMN IF EXISTS width;
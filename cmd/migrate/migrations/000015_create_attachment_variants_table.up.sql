@@ -0,0 +1,23 @@
+ALTER TABLE
+  IF EXISTS post_attachments
+ADD
+  COLUMN width INT NOT NULL DEFAULT 0;
+
+ALTER TABLE
+  IF EXISTS post_attachments
+ADD
+  COLUMN height INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS post_attachment_variants (
+  id bigserial PRIMARY KEY,
+  attachment_id bigint NOT NULL,
+  name varchar(20) NOT NULL,
+  storage_key text NOT NULL UNIQUE,
+  content_type varchar(255) NOT NULL,
+  width INT NOT NULL,
+  height INT NOT NULL,
+  size bigint NOT NULL,
+
+  UNIQUE (attachment_id, name),
+  FOREIGN KEY (attachment_id) REFERENCES post_attachments (id) ON DELETE CASCADE
+);
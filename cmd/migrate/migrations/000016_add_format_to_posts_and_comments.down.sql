@@ -0,0 +1,5 @@
+ALTER TABLE
+  IF EXISTS comments DROP COLUMN IF EXISTS format;
+
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS format;
@@ -0,0 +1,9 @@
+ALTER TABLE
+  IF EXISTS posts
+ADD
+  COLUMN format varchar(20) NOT NULL DEFAULT 'plain';
+
+ALTER TABLE
+  IF EXISTS comments
+ADD
+  COLUMN format varchar(20) NOT NULL DEFAULT 'plain';
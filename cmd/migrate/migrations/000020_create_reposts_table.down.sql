@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_quoted_post_id;
+
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS quoted_post_id;
+
+DROP TABLE IF EXISTS reposts;
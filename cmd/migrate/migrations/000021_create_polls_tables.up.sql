@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS polls (
+  id bigserial PRIMARY KEY,
+  post_id bigint NOT NULL UNIQUE,
+  multiple_choice boolean NOT NULL DEFAULT FALSE,
+  closes_at timestamp(0) with time zone,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+  id bigserial PRIMARY KEY,
+  poll_id bigint NOT NULL,
+  position INT NOT NULL,
+  text varchar(100) NOT NULL,
+
+  UNIQUE (poll_id, id),
+  FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE
+);
+
+-- one row per user and poll is what makes a vote final
+CREATE TABLE IF NOT EXISTS poll_votes (
+  poll_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (poll_id, user_id),
+  FOREIGN KEY (poll_id) REFERENCES polls (id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS poll_vote_choices (
+  poll_id bigint NOT NULL,
+  user_id bigint NOT NULL,
+  option_id bigint NOT NULL,
+
+  PRIMARY KEY (poll_id, user_id, option_id),
+  FOREIGN KEY (poll_id, user_id) REFERENCES poll_votes (poll_id, user_id) ON DELETE CASCADE,
+  FOREIGN KEY (poll_id, option_id) REFERENCES poll_options (poll_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_vote_choices_option_id ON poll_vote_choices (option_id);
@@ -0,0 +1,2 @@
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS visibility;
@@ -0,0 +1,4 @@
+ALTER TABLE
+  IF EXISTS posts
+ADD
+  COLUMN visibility varchar(20) NOT NULL DEFAULT 'public';
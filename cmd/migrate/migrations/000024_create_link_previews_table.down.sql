@@ -0,0 +1,4 @@
+ALTER TABLE
+  IF EXISTS posts DROP COLUMN IF EXISTS link_preview_url;
+
+DROP TABLE IF EXISTS link_previews;
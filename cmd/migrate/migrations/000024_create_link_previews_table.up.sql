@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS link_previews (
+  url text PRIMARY KEY,
+  title text NOT NULL DEFAULT '',
+  description text NOT NULL DEFAULT '',
+  image_url text NOT NULL DEFAULT '',
+  site_name text NOT NULL DEFAULT '',
+  fetched_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE
+  IF EXISTS posts
+ADD
+  COLUMN link_preview_url text REFERENCES link_previews (url) ON DELETE SET NULL;
@@ -0,0 +1,14 @@
+ALTER TABLE
+  IF EXISTS users
+ADD
+  COLUMN display_name varchar(50) NOT NULL DEFAULT '',
+ADD
+  COLUMN bio varchar(300) NOT NULL DEFAULT '',
+ADD
+  COLUMN website varchar(200) NOT NULL DEFAULT '',
+ADD
+  COLUMN location varchar(100) NOT NULL DEFAULT '',
+ADD
+  COLUMN avatar_url varchar(500) NOT NULL DEFAULT '',
+ADD
+  COLUMN username_changed_at timestamp(0) with time zone;
@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+  DROP COLUMN IF EXISTS device_label,
+  DROP COLUMN IF EXISTS user_agent,
+  DROP COLUMN IF EXISTS ip,
+  DROP COLUMN IF EXISTS last_seen_at;
@@ -0,0 +1,5 @@
+ALTER TABLE sessions
+  ADD COLUMN IF NOT EXISTS device_label varchar(100) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS user_agent varchar(500) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS ip varchar(45) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW();
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_totp (
+  user_id bigint PRIMARY KEY,
+  secret varchar(64) NOT NULL,
+  confirmed_at timestamp(0) with time zone,
+  last_step bigint NOT NULL DEFAULT 0,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  user_id bigint NOT NULL,
+  code_hash varchar(64) NOT NULL,
+  used_at timestamp(0) with time zone,
+
+  PRIMARY KEY (user_id, code_hash),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS user_identities (
+  provider varchar(50) NOT NULL,
+  subject varchar(255) NOT NULL,
+  user_id bigint NOT NULL,
+  email citext NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (provider, subject),
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
+
+CREATE TABLE IF NOT EXISTS oauth_states (
+  state_hash varchar(64) PRIMARY KEY,
+  provider varchar(50) NOT NULL,
+  nonce varchar(100) NOT NULL,
+  code_verifier varchar(100) NOT NULL,
+  device_label varchar(100) NOT NULL DEFAULT '',
+  expires_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_states_expires_at ON oauth_states (expires_at);
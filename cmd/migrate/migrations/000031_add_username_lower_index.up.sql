@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (lower(username));
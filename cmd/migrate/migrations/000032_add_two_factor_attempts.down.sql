@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS two_factor_challenges;
+
+ALTER TABLE user_totp
+  DROP COLUMN IF EXISTS failed_attempts,
+  DROP COLUMN IF EXISTS locked_until;
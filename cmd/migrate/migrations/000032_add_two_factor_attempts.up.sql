@@ -0,0 +1,11 @@
+ALTER TABLE user_totp
+  ADD COLUMN IF NOT EXISTS failed_attempts int NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS locked_until timestamp(0) with time zone;
+
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+  id varchar(64) PRIMARY KEY,
+  user_id bigint NOT NULL,
+  expires_at timestamp(0) with time zone NOT NULL,
+
+  FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -12,11 +12,14 @@ import (
 
 	"github.com/dunkykorZhik/social/docs"
 	"github.com/dunkykorZhik/social/internal/auth"
+	"github.com/dunkykorZhik/social/internal/blob"
 	"github.com/dunkykorZhik/social/internal/env"
 	"github.com/dunkykorZhik/social/internal/mailer"
+	"github.com/dunkykorZhik/social/internal/oidc"
 	"github.com/dunkykorZhik/social/internal/rateLimiter"
 	"github.com/dunkykorZhik/social/internal/storage"
 	"github.com/dunkykorZhik/social/internal/storage/cache"
+	"github.com/dunkykorZhik/social/internal/unfurl"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -35,6 +38,13 @@ type Application struct {
 	Mailer       mailer.Client
 	Auth         auth.Authenticator
 	RateLimiter  rateLimiter.RateLimiter
+	Blob         blob.Store
+	// Unfurler fetches the link previews of posts, link previews are off when it is nil.
+	Unfurler *unfurl.Unfurler
+	// OIDC holds the social login providers by name.
+	OIDC map[string]*oidc.Provider
+
+	previews chan previewJob
 }
 
 type Config struct {
@@ -46,6 +56,74 @@ type Config struct {
 	AuthConfig        AuthConfig
 	RedisConfig       RedisConfig
 	RateLimiterConfig rateLimiter.Config
+	PostConfig        PostConfig
+	MediaConfig       MediaConfig
+	ContentConfig     ContentConfig
+	TagConfig         TagConfig
+	PollConfig        PollConfig
+	PreviewConfig     PreviewConfig
+	ProfileConfig     ProfileConfig
+	TwoFactorConfig   TwoFactorConfig
+	OIDCConfig        OIDCConfig
+}
+
+type ProfileConfig struct {
+	UsernameCooldown time.Duration
+}
+
+// OIDCConfig lists the social login providers, a login has StateExp to come back from the provider.
+type OIDCConfig struct {
+	Providers []oidc.Config
+	StateExp  time.Duration
+}
+
+// TwoFactorConfig names the issuer shown by authenticator apps, a login has ChallengeExp to enter its code.
+type TwoFactorConfig struct {
+	Issuer       string
+	ChallengeExp time.Duration
+	// MaxAttempts failed codes in a row lock the second factor of a user for Lockout.
+	MaxAttempts int
+	Lockout     time.Duration
+}
+
+// PreviewConfig tunes the link preview worker, fetched previews are reused for CacheTTL.
+type PreviewConfig struct {
+	Enabled     bool
+	Timeout     time.Duration
+	MaxBodySize int64
+	CacheTTL    time.Duration
+	QueueSize   int
+}
+
+type PollConfig struct {
+	HideResultsUntilVoted bool
+}
+
+type TagConfig struct {
+	TrendingWindow time.Duration
+}
+
+// ContentConfig limits the length of post and comment content per format, Markdown needs room for its markup.
+type ContentConfig struct {
+	PlainMaxLength    int
+	MarkdownMaxLength int
+}
+
+type MediaConfig struct {
+	Dir            string
+	BaseURL        string
+	MaxFileSize    int64
+	MaxAttachments int
+	// URLSecret signs the media links, which are valid for up to URLExp.
+	URLSecret string
+	URLExp    time.Duration
+}
+
+type PostConfig struct {
+	TrashRetention  time.Duration
+	PurgeInterval   time.Duration
+	PublishInterval time.Duration
+	MaxPinned       int
 }
 
 type RedisConfig struct {
@@ -60,10 +138,16 @@ type AuthConfig struct {
 	Token TokenConfig
 }
 
+// TokenConfig sets the lifetime of the access tokens, Exp, and of the refresh tokens that renew them, RefreshExp.
+// Keys lists the signing keys for auth.LoadKeys, without keys the tokens are signed with the HS256 Secret.
+// KeyGrace is how long the tokens of a rotated out key stay valid.
 type TokenConfig struct {
-	Secret string
-	Exp    time.Duration
-	Iss    string
+	Secret     string
+	Keys       string
+	KeyGrace   time.Duration
+	Exp        time.Duration
+	RefreshExp time.Duration
+	Iss        string
 }
 
 type BasicConfig struct {
@@ -106,31 +190,94 @@ func (app *Application) Mount() http.Handler {
 	// processing should be stopped.
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Route("/v1", func(r chi.Router) {
 		r.With(app.basicAuthMiddleWare).Get("/health", app.healthCheckHandler)
 
 		sUrl := fmt.Sprintf("%s/swagger/doc.json", app.Config.Addr)
 		r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL(sUrl)))
 
+		r.Get("/media/*", app.getMediaHandler)
+
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.authMaiddleWare)
 			r.Post("/", app.createPostHandler)
+			r.With(app.deletedPostContextMiddleWare).Post("/{postID}/restore", app.checkPermission("moderator", app.restorePostHandler))
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postContextMiddleWare)
 				r.Get("/", app.getPostHandler)
 				r.Delete("/", app.checkPermission("moderator", app.deletePostHandler))
 				r.Patch("/", app.checkPermission("admin", app.updatePostHandler))
+
+				r.Route("/revisions", func(r chi.Router) {
+					r.Get("/", app.listRevisionsHandler)
+					r.Get("/{version}", app.getRevisionHandler)
+					r.Post("/{version}/restore", app.checkPermission("admin", app.restoreRevisionHandler))
+				})
+
+				r.Route("/attachments", func(r chi.Router) {
+					r.Post("/", app.checkPermission("admin", app.uploadAttachmentsHandler))
+					r.Delete("/{attachmentID}", app.checkPermission("admin", app.deleteAttachmentHandler))
+				})
+
+				r.Put("/reactions/{kind}", app.addReactionHandler)
+				r.Delete("/reactions/{kind}", app.removeReactionHandler)
+
+				r.Put("/bookmark", app.addBookmarkHandler)
+				r.Delete("/bookmark", app.removeBookmarkHandler)
+
+				r.Put("/repost", app.addRepostHandler)
+				r.Delete("/repost", app.removeRepostHandler)
+
+				r.Put("/pin", app.pinPostHandler)
+				r.Delete("/pin", app.unpinPostHandler)
+
+				r.Post("/poll/votes", app.votePollHandler)
+
+				r.Route("/comments", func(r chi.Router) {
+					r.Get("/", app.listCommentsHandler)
+					r.Post("/", app.createCommentHandler)
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.commentContextMiddleWare)
+						r.Patch("/", app.checkCommentPermission("admin", app.updateCommentHandler))
+						r.Delete("/", app.checkCommentPermission("moderator", app.deleteCommentHandler))
+					})
+				})
 			})
 
 		})
 
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/trending", app.getTrendingTagsHandler)
+			r.Get("/{tag}/posts", app.getTagPostsHandler)
+		})
+
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
 
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.authMaiddleWare)
+				r.Get("/", app.getMeHandler)
+				r.Patch("/", app.updateMeHandler)
+				r.Get("/mentions", app.listMentionsHandler)
+				r.Get("/bookmarks", app.listBookmarksHandler)
+				r.Put("/pins", app.reorderPinsHandler)
+				r.Get("/sessions", app.listSessionsHandler)
+				r.Delete("/sessions/{sessionID}", app.revokeSessionHandler)
+
+				r.Post("/2fa", app.enrollTwoFactorHandler)
+				r.Post("/2fa/confirm", app.confirmTwoFactorHandler)
+				r.Delete("/2fa", app.disableTwoFactorHandler)
+			})
+
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.authMaiddleWare)
 
 				r.Get("/", app.getUserHandler)
+				r.Get("/posts", app.getUserPostsHandler)
+				r.Get("/followers", app.listFollowersHandler)
+				r.Get("/following", app.listFollowingHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
 			})
@@ -144,6 +291,11 @@ func (app *Application) Mount() http.Handler {
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/register", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/token/2fa", app.createTwoFactorTokenHandler)
+			r.Get("/oidc/{provider}", app.oidcLoginHandler)
+			r.Get("/oidc/{provider}/callback", app.oidcCallbackHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/logout", app.logoutHandler)
 		})
 	})
 	return r
@@ -162,6 +314,15 @@ func (app *Application) Run(h *http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go app.runJob(jobsCtx, "purge deleted posts", app.Config.PostConfig.PurgeInterval, app.purgeDeletedPosts)
+	go app.runJob(jobsCtx, "publish scheduled posts", app.Config.PostConfig.PublishInterval, app.publishScheduledPosts)
+	if app.Unfurler != nil {
+		app.previews = make(chan previewJob, app.Config.PreviewConfig.QueueSize)
+		go app.runPreviewWorker(jobsCtx)
+	}
+
 	shutdown := make(chan error)
 
 	go func() {
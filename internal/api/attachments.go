@@ -0,0 +1,354 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/blob"
+	"github.com/dunkykorZhik/social/internal/media"
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// attachmentTypes maps the sniffed content types we accept to the extension their blobs are stored with.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// imageVariantSizes are the renditions generated for image attachments that are larger than them.
+var imageVariantSizes = []media.Size{
+	{Name: "thumb", MaxSize: 320},
+	{Name: "medium", MaxSize: 1080},
+}
+
+// maxImagePixels guards the decoder against images that are small on disk but huge in memory.
+const maxImagePixels = 50_000_000
+
+// UploadAttachments godoc
+//
+//	@Summary		Uploads attachments to a post
+//	@Description	Uploads one or more files sent as multipart "file" fields
+//	@Tags			posts
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			file	formData	file	true	"Attachment"
+//	@Success		201		{object}	[]storage.Attachment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		413		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/attachments [post]
+func (app *Application) uploadAttachmentsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.Config.MediaConfig
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.MaxFileSize*int64(cfg.MaxAttachments)+1<<20)
+	if err := r.ParseMultipartForm(cfg.MaxFileSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.payloadTooLargeResponse(w, r, err)
+			return
+		}
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		app.badRequestReponse(w, r, fmt.Errorf("no file was uploaded"))
+		return
+	}
+
+	// The attachments the post already has are counted by Attachments.Create, this only spares the processing.
+	errLimit := fmt.Errorf("a post can have at most %d attachments", cfg.MaxAttachments)
+	if len(files) > cfg.MaxAttachments {
+		app.badRequestReponse(w, r, errLimit)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	ctx := r.Context()
+
+	// The blobs of the files stored so far are deleted when a later file fails, so a failed upload leaves nothing.
+	attachments := []storage.Attachment{}
+	discard := func() {
+		for i := range attachments {
+			app.deleteAttachmentBlobs(ctx, &attachments[i])
+		}
+	}
+	for _, fh := range files {
+		if fh.Size > cfg.MaxFileSize {
+			discard()
+			app.payloadTooLargeResponse(w, r, fmt.Errorf("%s is larger than %d bytes", fh.Filename, cfg.MaxFileSize))
+			return
+		}
+
+		file, err := fh.Open()
+		if err != nil {
+			discard()
+			app.internalServerError(w, r, err)
+			return
+		}
+		attachment, err := app.storeAttachment(r, post.ID, fh.Filename, file)
+		file.Close()
+		if err != nil {
+			discard()
+			if errors.Is(err, errUnsupportedMedia) {
+				app.badRequestReponse(w, r, err)
+				return
+			}
+			app.internalServerError(w, r, err)
+			return
+		}
+		attachments = append(attachments, *attachment)
+	}
+	if err := app.Storage.Attachments.Create(ctx, attachments, cfg.MaxAttachments); err != nil {
+		discard()
+		switch {
+		case errors.Is(err, storage.ErrAttachmentLimit):
+			app.badRequestReponse(w, r, errLimit)
+			return
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, app.withAttachmentURLs(attachments, getUserFromCtx(r).ID)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteAttachment godoc
+//
+//	@Summary		Deletes an attachment
+//	@Description	Deletes an attachment of a post by ID
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID			path		int	true	"Post ID"
+//	@Param			attachmentID	path		int	true	"Attachment ID"
+//	@Success		204				{object}	string
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/attachments/{attachmentID} [delete]
+func (app *Application) deleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	attachmentID, err := strconv.ParseInt(chi.URLParam(r, "attachmentID"), 10, 64)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	post := getPostFromCtx(r)
+	attachment, err := app.Storage.Attachments.GetByID(ctx, attachmentID)
+	if err == nil && attachment.PostID != post.ID {
+		err = storage.ErrNotFound
+	}
+	if err == nil {
+		err = app.Storage.Attachments.Delete(ctx, attachmentID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.deleteAttachmentBlobs(ctx, attachment)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getMediaHandler serves the blob of an attachment, or of one of its variants, to the users who can see its post.
+// The link carries the viewer and a signature, see mediaURL, instead of an access token, and the post is checked
+// again so that a link stops working once its post is hidden from the viewer.
+func (app *Application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	viewerID, err := app.verifyMediaURL(key, r.URL.Query())
+	if err != nil {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	if _, err := app.Storage.Attachments.GetByKey(r.Context(), key, viewerID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, blob.ErrNotFound)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	file, err := app.Blob.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound), errors.Is(err, blob.ErrInvalidKey):
+			app.notFoundReponse(w, r, blob.ErrNotFound)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// The post can be hidden later, so shared caches must not keep the blob.
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if _, err := io.Copy(w, file); err != nil {
+		app.Logger.Errorw("error writing media", "key", key, "error", err.Error())
+	}
+}
+
+var (
+	errUnsupportedMedia = errors.New("unsupported file type")
+	errInvalidMediaURL  = errors.New("the media link is invalid or expired")
+)
+
+// storeAttachment sniffs the content type of the upload, strips its metadata, renders the image variants and
+// writes everything to the blob store. The caller records the returned attachment with Attachments.Create.
+func (app *Application) storeAttachment(r *http.Request, postID int64, filename string, file io.Reader) (*storage.Attachment, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := attachmentTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", errUnsupportedMedia, contentType)
+	}
+
+	processed, err := media.Process(contentType, data, imageVariantSizes, maxImagePixels)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedMedia, err.Error())
+	}
+
+	ctx := r.Context()
+	name := fmt.Sprintf("posts/%d/%s", postID, uuid.New().String())
+	attachment := &storage.Attachment{
+		PostID:      postID,
+		Key:         name + ext,
+		Filename:    path.Base(filename),
+		ContentType: contentType,
+		Size:        int64(len(processed.Data)),
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Variants:    []storage.AttachmentVariant{},
+	}
+	for _, v := range processed.Variants {
+		attachment.Variants = append(attachment.Variants, storage.AttachmentVariant{
+			Name:        v.Name,
+			Key:         fmt.Sprintf("%s_%s%s", name, v.Name, attachmentTypes[v.ContentType]),
+			ContentType: v.ContentType,
+			Width:       v.Width,
+			Height:      v.Height,
+			Size:        int64(len(v.Data)),
+		})
+	}
+
+	err = app.Blob.Put(ctx, attachment.Key, bytes.NewReader(processed.Data))
+	for i := 0; err == nil && i < len(processed.Variants); i++ {
+		err = app.Blob.Put(ctx, attachment.Variants[i].Key, bytes.NewReader(processed.Variants[i].Data))
+	}
+	if err != nil {
+		app.deleteAttachmentBlobs(ctx, attachment)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (app *Application) deleteAttachmentBlobs(ctx context.Context, attachment *storage.Attachment) {
+	keys := []string{attachment.Key}
+	for _, variant := range attachment.Variants {
+		keys = append(keys, variant.Key)
+	}
+	for _, key := range keys {
+		if err := app.Blob.Delete(ctx, key); err != nil {
+			app.Logger.Errorw("error deleting attachment blob", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// withAttachmentURLs fills in the media links of the attachments, signed for the viewer so that they can be used
+// as the src of an img without an Authorization header.
+func (app *Application) withAttachmentURLs(attachments []storage.Attachment, viewerID int64) []storage.Attachment {
+	for i := range attachments {
+		attachments[i].URL = app.mediaURL(attachments[i].Key, viewerID)
+		for j := range attachments[i].Variants {
+			attachments[i].Variants[j].URL = app.mediaURL(attachments[i].Variants[j].Key, viewerID)
+		}
+	}
+	return attachments
+}
+
+// mediaURL signs the link to a blob for the viewer. The expiry is rounded up to half of MediaConfig.URLExp, so
+// the links of a blob stay the same for a while and browsers can cache them.
+func (app *Application) mediaURL(key string, viewerID int64) string {
+	window := app.Config.MediaConfig.URLExp / 2
+	exp := time.Now().Add(window).Truncate(window).Add(window).Unix()
+	q := url.Values{}
+	q.Set("uid", strconv.FormatInt(viewerID, 10))
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", app.mediaSignature(key, viewerID, exp))
+	return app.Blob.URL(key) + "?" + q.Encode()
+}
+
+// verifyMediaURL checks the signature and the expiry of a media link and returns the viewer it was signed for.
+func (app *Application) verifyMediaURL(key string, q url.Values) (int64, error) {
+	viewerID, err := strconv.ParseInt(q.Get("uid"), 10, 64)
+	if err != nil {
+		return 0, errInvalidMediaURL
+	}
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		return 0, errInvalidMediaURL
+	}
+	if !hmac.Equal([]byte(q.Get("sig")), []byte(app.mediaSignature(key, viewerID, exp))) {
+		return 0, errInvalidMediaURL
+	}
+	if time.Now().Unix() > exp {
+		return 0, errInvalidMediaURL
+	}
+	return viewerID, nil
+}
+
+func (app *Application) mediaSignature(key string, viewerID, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(app.Config.MediaConfig.URLSecret))
+	fmt.Fprintf(mac, "%s\n%d\n%d", key, viewerID, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/blob"
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func newUploadRequest(t *testing.T, url string, contents ...[]byte) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for _, content := range contents {
+		fw, err := mw.CreateFormFile("file", "upload.bin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(content)
+	}
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestAttachments(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should upload an image with its thumbnails", func(t *testing.T) {
+		img := &bytes.Buffer{}
+		if err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, 640, 400))); err != nil {
+			t.Fatal(err)
+		}
+		req := newUploadRequest(t, "/v1/posts/1/attachments", img.Bytes())
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+		if !strings.Contains(rr.Body.String(), `"name":"thumb","content_type":"image/png","width":320,"height":200`) {
+			t.Errorf("Expected a 320x200 thumbnail, but got %s", rr.Body.String())
+		}
+	})
+	t.Run("should reject an unsupported file type", func(t *testing.T) {
+		req := newUploadRequest(t, "/v1/posts/1/attachments", []byte("<html><script></script></html>"))
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should reject a file over the size limit", func(t *testing.T) {
+		req := newUploadRequest(t, "/v1/posts/1/attachments", make([]byte, 2<<20))
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusRequestEntityTooLarge)
+	})
+	t.Run("should limit the attachments of a post", func(t *testing.T) {
+		app := newTestApplication(t)
+		mux := app.Mount()
+		img := &bytes.Buffer{}
+		if err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+			t.Fatal(err)
+		}
+		for _, upload := range []struct {
+			files  int
+			status int
+		}{{3, http.StatusCreated}, {2, http.StatusBadRequest}, {1, http.StatusCreated}, {1, http.StatusBadRequest}} {
+			contents := make([][]byte, upload.files)
+			for i := range contents {
+				contents[i] = img.Bytes()
+			}
+			req := newUploadRequest(t, "/v1/posts/1/attachments", contents...)
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, upload.status)
+		}
+		if keys := app.Blob.(*blob.MockStore).Keys(); len(keys) != app.Config.MediaConfig.MaxAttachments {
+			t.Errorf("Expected the blobs of %d attachments, but got %v", app.Config.MediaConfig.MaxAttachments, keys)
+		}
+	})
+	t.Run("should not keep the blobs of a failed upload", func(t *testing.T) {
+		app := newTestApplication(t)
+		mux := app.Mount()
+		img := &bytes.Buffer{}
+		if err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, 640, 400))); err != nil {
+			t.Fatal(err)
+		}
+		req := newUploadRequest(t, "/v1/posts/1/attachments", img.Bytes(), []byte("<html><script></script></html>"))
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+		if keys := app.Blob.(*blob.MockStore).Keys(); len(keys) != 0 {
+			t.Errorf("Expected no blobs, but got %v", keys)
+		}
+	})
+}
+
+func TestMedia(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	img := &bytes.Buffer{}
+	if err := png.Encode(img, image.NewRGBA(image.Rect(0, 0, 64, 40))); err != nil {
+		t.Fatal(err)
+	}
+	req := newUploadRequest(t, "/v1/posts/1/attachments", img.Bytes())
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponse(t, rr.Code, http.StatusCreated)
+	var body struct {
+		Data []storage.Attachment `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || len(body.Data) != 1 {
+		t.Fatalf("Expected one attachment, but got %v", err)
+	}
+	url := body.Data[0].URL
+
+	t.Run("should serve the blob of a visible post without a token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+		if rr.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("Expected image/png, but got %s", rr.Header().Get("Content-Type"))
+		}
+	})
+	t.Run("should require a valid signature", func(t *testing.T) {
+		for _, link := range []string{
+			strings.Split(url, "?")[0],
+			strings.Replace(url, "uid=", "uid=9", 1),
+			strings.Replace(url, "posts/1/", "posts/1/x", 1),
+		} {
+			req, err := http.NewRequest(http.MethodGet, link, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, http.StatusForbidden)
+		}
+	})
+	t.Run("should refuse an expired link", func(t *testing.T) {
+		exp := time.Now().Add(-time.Minute).Unix()
+		link := fmt.Sprintf("/v1/media/posts/1/old.png?uid=1&exp=%d&sig=%s", exp, app.mediaSignature("posts/1/old.png", 1, exp))
+		req, err := http.NewRequest(http.MethodGet, link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusForbidden)
+	})
+	t.Run("should not serve the blob of a hidden post", func(t *testing.T) {
+		if err := app.Blob.Put(context.Background(), "posts/2/hidden.png", bytes.NewReader(img.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodGet, app.mediaURL("posts/2/hidden.png", 1), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNotFound)
+	})
+}
@@ -6,11 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/dunkykorZhik/social/internal/mailer"
 	"github.com/dunkykorZhik/social/internal/storage"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -21,8 +19,9 @@ type RegisterPayLoad struct {
 }
 
 type CreateTokenPayLoad struct {
-	Email    string `json:"email" validate:"required,email,max=255"`
-	Password string `json:"password" validate:"required,min=3,max=70"`
+	Email       string `json:"email" validate:"required,email,max=255"`
+	Password    string `json:"password" validate:"required,min=3,max=70"`
+	DeviceLabel string `json:"device_label" validate:"omitempty,max=100"`
 }
 
 // registerUserHandler godoc
@@ -105,12 +104,14 @@ func (app *Application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 // createTokenHandler godoc
 //
 //	@Summary		Creates a token
-//	@Description	Creates a token for a user
+//	@Description	Logs the user in with a short-lived access token and a refresh token that renews it. Users with two
+//	@Description	factor authentication get a challenge token instead, see /authentication/token/2fa
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
 //	@Param			payload	body		CreateTokenPayLoad	true	"User credentials"
-//	@Success		200		{string}	string					"Token"
+//	@Success		201		{object}	TokenResponse
+//	@Success		202		{object}	TwoFactorChallenge
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		500		{object}	error
@@ -143,23 +144,41 @@ func (app *Application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	claims := jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(app.Config.AuthConfig.Token.Exp).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
-		"iss": app.Config.AuthConfig.Token.Iss,
-		"aud": app.Config.AuthConfig.Token.Iss,
+	app.login(w, r, user, payload.DeviceLabel)
+}
+
+// login answers a successful first login step: with the tokens of a new session, or with a two factor challenge
+// when the user enabled it.
+func (app *Application) login(w http.ResponseWriter, r *http.Request, user *storage.User, deviceLabel string) {
+	tf, err := app.Storage.TwoFactor.GetByUserID(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if tf != nil && tf.Enabled {
+		challenge, err := app.twoFactorChallenge(r.Context(), user, deviceLabel)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if err := app.jsonResponse(w, http.StatusAccepted, challenge); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
 
-	token, err := app.Auth.GenerateToken(claims)
+	session := &storage.Session{
+		UserID:      user.ID,
+		DeviceLabel: deviceLabel,
+		UserAgent:   userAgent(r),
+		IP:          clientIP(r),
+	}
+	tokens, err := app.startSession(r.Context(), session)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
 		app.internalServerError(w, r, err)
-		return
 	}
-
 }
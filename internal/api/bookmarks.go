@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+// AddBookmark godoc
+//
+//	@Summary		Bookmarks a post
+//	@Description	Saves a post to read later, bookmarking twice has no effect
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{object}	string
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *Application) addBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	if err := app.Storage.Bookmarks.Add(r.Context(), post.ID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveBookmark godoc
+//
+//	@Summary		Removes a bookmark
+//	@Description	Removes a post from the saved posts, removing a missing bookmark has no effect
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{object}	string
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *Application) removeBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	if err := app.Storage.Bookmarks.Remove(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListBookmarks godoc
+//
+//	@Summary		Fetches the bookmarked posts
+//	@Description	Fetches the posts the user saved, most recently saved first by default
+//	@Tags			bookmarks
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]storage.PostForFeed
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/bookmarks [get]
+func (app *Application) listBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	pq, ok := app.readPaginateQuery(w, r)
+	if !ok {
+		return
+	}
+
+	user := getUserFromCtx(r)
+	posts, err := app.Storage.Bookmarks.GetByUserID(r.Context(), user.ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.prepareFeed(posts, user.ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
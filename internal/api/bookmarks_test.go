@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should bookmark a post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/bookmark", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should remove a bookmark", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/bookmark", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should list the bookmarks filtered by tag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/bookmarks?tags=Go,news&search=x", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should not allow unauthenticated users", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/bookmarks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusUnauthorized)
+	})
+}
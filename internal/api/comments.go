@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+var defaultCommentQuery = storage.CommentQuery{
+	Limit: 20,
+	Sort:  storage.CommentSortNewest,
+}
+
+// maxCommentDepth limits how deep a reply thread can nest, top level comments have depth 0.
+const maxCommentDepth = 5
+
+type CreateCommentPayLoad struct {
+	Content  string `json:"content" validate:"required"`
+	Format   string `json:"format" validate:"omitempty,oneof=plain markdown"`
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+}
+
+type UpdateCommentPayLoad struct {
+	Content string  `json:"content" validate:"required"`
+	Format  *string `json:"format" validate:"omitempty,oneof=plain markdown"`
+}
+
+// ListComments godoc
+//
+//	@Summary		Fetches the comments of a post
+//	@Description	Fetches a page of top level comments with their replies. The most_replied order is frozen when the
+//	@Description	first page is fetched, later pages rank by the reply counts of that moment
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			sort	query		string	false	"Sort"	Enums(newest, oldest, most_replied)
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	storage.CommentPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *Application) listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	cq, err := defaultCommentQuery.Parse(r)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	page, err := app.Storage.Comments.GetPageByPostID(r.Context(), post.ID, cq)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidCursor):
+			app.badRequestReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	renderComments(page.Comments)
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateComment godoc
+//
+//	@Summary		Creates a comment
+//	@Description	Creates a comment on a post
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int						true	"Post ID"
+//	@Param			payload	body		CreateCommentPayLoad	true	"Comment payload"
+//	@Success		201		{object}	storage.Comment
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments [post]
+func (app *Application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateCommentPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	format := contentFormat(payload.Format)
+	if err := app.checkContentLength(payload.Content, format); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	comment := &storage.Comment{
+		PostID:   post.ID,
+		UserID:   user.ID,
+		ParentID: payload.ParentID,
+		Content:  payload.Content,
+		Format:   format,
+		Mentions: extractMentions(payload.Content),
+		User:     *user,
+	}
+
+	ctx := r.Context()
+	if payload.ParentID != nil {
+		parent, err := app.Storage.Comments.GetByID(ctx, *payload.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				app.badRequestReponse(w, r, fmt.Errorf("parent comment not found"))
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+		if parent.PostID != post.ID {
+			app.badRequestReponse(w, r, fmt.Errorf("parent comment belongs to another post"))
+			return
+		}
+		if parent.Depth >= maxCommentDepth {
+			app.badRequestReponse(w, r, fmt.Errorf("replies cannot be nested deeper than %d levels", maxCommentDepth))
+			return
+		}
+		comment.Depth = parent.Depth + 1
+	}
+
+	if err := app.Storage.Comments.Create(ctx, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	comment.ContentHTML = renderContent(comment.Content, comment.Format)
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Updates a comment
+//	@Description	Updates a comment by ID
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"Post ID"
+//	@Param			commentID	path		int						true	"Comment ID"
+//	@Param			payload		body		UpdateCommentPayLoad	true	"Comment payload"
+//	@Success		200			{object}	storage.Comment
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *Application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateCommentPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	comment := getCommentFromCtx(r)
+	comment.Content = payload.Content
+	if payload.Format != nil {
+		comment.Format = *payload.Format
+	}
+	comment.Format = contentFormat(comment.Format)
+	if err := app.checkContentLength(comment.Content, comment.Format); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	comment.Mentions = extractMentions(comment.Content)
+
+	if err := app.Storage.Comments.Update(r.Context(), comment); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	comment.ContentHTML = renderContent(comment.Content, comment.Format)
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Deletes a comment
+//	@Description	Deletes a comment by ID
+//	@Tags			comments
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int	true	"Post ID"
+//	@Param			commentID	path		int	true	"Comment ID"
+//	@Success		204			{object}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *Application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromCtx(r)
+
+	if err := app.Storage.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *Application) commentContextMiddleWare(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+		if err != nil {
+			app.badRequestReponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+		comment, err := app.Storage.Comments.GetByID(ctx, commentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				app.notFoundReponse(w, r, err)
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+
+		post := getPostFromCtx(r)
+		if comment.PostID != post.ID {
+			app.notFoundReponse(w, r, storage.ErrNotFound)
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getCommentFromCtx(r *http.Request) *storage.Comment {
+	comment := r.Context().Value(commentCtx).(*storage.Comment)
+	return comment
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestComments(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should not allow unauthenticated users to comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments", strings.NewReader(`{"content":"hello"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusUnauthorized)
+	})
+	t.Run("should list comments", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/comments?sort=most_replied&limit=10", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should reject an unknown sort mode", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/comments?sort=popular", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should create a comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments", strings.NewReader(`{"content":"hello"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+	})
+	t.Run("should reject an empty comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments", strings.NewReader(`{"content":""}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should reply to a comment of the same post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments", strings.NewReader(`{"content":"hello","parent_id":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+	})
+	t.Run("should not reply to a comment of another post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/2/comments", strings.NewReader(`{"content":"hello","parent_id":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should not find a comment of another post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/2/comments/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNotFound)
+	})
+	t.Run("should allow the owner to delete a comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/comments/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+}
+
+func TestCommentModeration(t *testing.T) {
+	app := newTestApplication(t)
+	asViewer(app, storage.User{Role_id: 2})
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should not let another user delete a comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/comments/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusForbidden)
+	})
+	t.Run("should not let another user edit a comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, "/v1/posts/1/comments/1", strings.NewReader(`{"content":"edited"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusForbidden)
+	})
+	t.Run("should let a moderator delete a comment", func(t *testing.T) {
+		asViewer(app, storage.User{Role_id: 3})
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/comments/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+}
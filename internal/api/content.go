@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/dunkykorZhik/social/internal/markdown"
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+// contentFormat defaults an omitted format to plain text.
+func contentFormat(format string) string {
+	if format == "" {
+		return storage.ContentFormatPlain
+	}
+	return format
+}
+
+// checkContentLength enforces the length limit configured for the content's format, counted in characters.
+func (app *Application) checkContentLength(content, format string) error {
+	limit := app.Config.ContentConfig.PlainMaxLength
+	if format == storage.ContentFormatMarkdown {
+		limit = app.Config.ContentConfig.MarkdownMaxLength
+	}
+	if utf8.RuneCountInString(content) > limit {
+		return fmt.Errorf("%s content must be at most %d characters long", format, limit)
+	}
+	return nil
+}
+
+func renderContent(content, format string) string {
+	if format == storage.ContentFormatMarkdown {
+		return markdown.Render(content)
+	}
+	return markdown.RenderPlain(content)
+}
+
+// renderPost fills in the HTML of the post and of the comments embedded in it.
+func renderPost(post *storage.Post) {
+	post.ContentHTML = renderContent(post.Content, post.Format)
+	renderComments(post.Comments)
+}
+
+// renderComments fills in the HTML of the comments and all their replies.
+func renderComments(comments []storage.Comment) {
+	for i := range comments {
+		comments[i].ContentHTML = renderContent(comments[i].Content, comments[i].Format)
+		renderComments(comments[i].Replies)
+	}
+}
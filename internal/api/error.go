@@ -9,8 +9,8 @@ func (app *Application) internalServerError(w http.ResponseWriter, r *http.Reque
 	writeJSONError(w, http.StatusInternalServerError, "the server encountered a problem")
 }
 func (app *Application) conflictError(w http.ResponseWriter, r *http.Request, err error) {
-	app.Logger.Errorw("conflict error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	writeJSONError(w, http.StatusConflict, "the conflict with resources")
+	app.Logger.Warnw("conflict error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJSONError(w, http.StatusConflict, err.Error())
 }
 
 func (app *Application) badRequestReponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -22,6 +22,11 @@ func (app *Application) notFoundReponse(w http.ResponseWriter, r *http.Request,
 	writeJSONError(w, http.StatusNotFound, err.Error())
 }
 
+func (app *Application) payloadTooLargeResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.Logger.Warnw("payload too large", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJSONError(w, http.StatusRequestEntityTooLarge, err.Error())
+}
+
 func (app *Application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
 	app.Logger.Warnf("forbidden", "method", r.Method, "path", r.URL.Path)
 	writeJSONError(w, http.StatusForbidden, "forbidden")
@@ -39,6 +44,11 @@ func (app *Application) unAuthError(w http.ResponseWriter, r *http.Request, err
 	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
 }
 
+func (app *Application) tooManyRequestsResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.Logger.Warnw("too many requests", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	writeJSONError(w, http.StatusTooManyRequests, err.Error())
+}
+
 func (app *Application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
 	app.Logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path)
 
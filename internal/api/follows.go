@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+var defaultFollowQuery = storage.FollowQuery{
+	Limit: 20,
+}
+
+// ListFollowers godoc
+//
+//	@Summary		Fetches the followers of a user
+//	@Description	Fetches a page of the users following the user, most recent follow first
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	storage.FollowPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/followers [get]
+func (app *Application) listFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.Storage.Users.GetFollowers)
+}
+
+// ListFollowing godoc
+//
+//	@Summary		Fetches the users a user follows
+//	@Description	Fetches a page of the users followed by the user, most recent follow first
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor"
+//	@Success		200		{object}	storage.FollowPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/following [get]
+func (app *Application) listFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.listFollows(w, r, app.Storage.Users.GetFollowing)
+}
+
+func (app *Application) listFollows(w http.ResponseWriter, r *http.Request,
+	getPage func(context.Context, int64, int64, storage.FollowQuery) (*storage.FollowPage, error)) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	fq, err := defaultFollowQuery.Parse(r)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if _, err := app.getUser(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	page, err := getPage(r.Context(), userID, getUserFromCtx(r).ID, fq)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrInvalidCursor):
+			app.badRequestReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// withUserStats returns a copy of the user carrying its counts and, unless the viewer looks at their own profile,
+// the relationship between the two. The cached user is left untouched.
+func (app *Application) withUserStats(ctx context.Context, user *storage.User, viewerID int64) (*storage.User, error) {
+	stats, err := app.Storage.Users.GetStats(ctx, user.ID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	profile := *user
+	profile.FollowerCount = &stats.FollowerCount
+	profile.FollowingCount = &stats.FollowingCount
+	profile.PostCount = &stats.PostCount
+	if user.ID != viewerID {
+		profile.Relationship = &stats.Relationship
+	}
+	return &profile, nil
+}
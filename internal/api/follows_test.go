@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFollows(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should list followers and followed users", func(t *testing.T) {
+		for _, path := range []string{"/v1/users/1/followers", "/v1/users/1/following?limit=5"} {
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, http.StatusOK)
+		}
+	})
+	t.Run("should validate the page size", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1/followers?limit=100", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should add the counts to the own profile", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+		for _, field := range []string{`"follower_count":0`, `"following_count":0`, `"post_count":0`} {
+			if !strings.Contains(rr.Body.String(), field) {
+				t.Errorf("Expected %s in %s", field, rr.Body.String())
+			}
+		}
+		if strings.Contains(rr.Body.String(), `"relationship"`) {
+			t.Errorf("Expected no relationship with oneself, but got %s", rr.Body.String())
+		}
+	})
+}
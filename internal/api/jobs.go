@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// runJob calls fn every interval until ctx is cancelled, failures are logged and retried on the next tick.
+func (app *Application) runJob(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				app.Logger.Errorw("background job failed", "job", name, "error", err.Error())
+			}
+		}
+	}
+}
+
+func (app *Application) purgeDeletedPosts(ctx context.Context) error {
+	deletedBefore := time.Now().Add(-app.Config.PostConfig.TrashRetention)
+	keys, err := app.Storage.Attachments.GetKeysDeletedBefore(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	purged, err := app.Storage.Posts.Purge(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := app.Blob.Delete(ctx, key); err != nil {
+			app.Logger.Errorw("error deleting attachment blob", "key", key, "error", err.Error())
+		}
+	}
+	if purged > 0 {
+		app.Logger.Infow("purged deleted posts", "count", purged)
+	}
+	return nil
+}
+
+func (app *Application) publishScheduledPosts(ctx context.Context) error {
+	published, err := app.Storage.Posts.PublishScheduled(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if published > 0 {
+		app.Logger.Infow("published scheduled posts", "count", published)
+	}
+	return nil
+}
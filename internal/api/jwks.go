@@ -0,0 +1,12 @@
+package api
+
+import "net/http"
+
+// jwksHandler publishes the public keys that verify the access tokens as a JSON Web Key Set. It is served outside
+// of /v1 at the well-known path other services look it up at.
+func (app *Application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := writeJSON(w, http.StatusOK, app.Auth.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJWKS(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	t.Run("should publish the key set", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+		if !strings.HasPrefix(rr.Body.String(), `{"keys":`) {
+			t.Errorf("Expected a bare key set, but got %s", rr.Body.String())
+		}
+	})
+}
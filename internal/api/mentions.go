@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/dunkykorZhik/social/internal/entities"
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+// extractMentions finds the @usernames in the content, the storage resolves them to users when saving.
+func extractMentions(content string) []storage.Mention {
+	found := entities.Mentions(content)
+	mentions := make([]storage.Mention, len(found))
+	for i, entity := range found {
+		mentions[i] = storage.Mention{
+			Username: entity.Text,
+			Start:    entity.Start,
+			End:      entity.End,
+		}
+	}
+	return mentions
+}
+
+// ListMentions godoc
+//
+//	@Summary		Fetches the posts and comments mentioning the user
+//	@Description	Fetches the posts and comments mentioning the authenticated user, newest first by default
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]storage.MentionItem
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/mentions [get]
+func (app *Application) listMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	pq, ok := app.readPaginateQuery(w, r)
+	if !ok {
+		return
+	}
+
+	user := getUserFromCtx(r)
+	items, err := app.Storage.Mentions.GetByUserID(r.Context(), user.ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range items {
+		items[i].ContentHTML = renderContent(items[i].Content, items[i].Format)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, items); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
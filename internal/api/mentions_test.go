@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestMentions(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should list the mentions of the caller", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/mentions?limit=10", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should reject an invalid page size", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/mentions?limit=100", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should extract mentions from a new comment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments", strings.NewReader(`{"content":"thanks @alice"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+
+		var res struct {
+			Data storage.Comment `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		want := storage.Mention{Username: "alice", Start: 7, End: 13}
+		if len(res.Data.Mentions) != 1 || res.Data.Mentions[0] != want {
+			t.Errorf("Expected %v, but got %v", want, res.Data.Mentions)
+		}
+	})
+}
@@ -46,6 +46,18 @@ import (
 } */
 
 func (app *Application) postContextMiddleWare(next http.Handler) http.Handler {
+	return app.loadPostContext(func(r *http.Request, postID int64) (*storage.Post, error) {
+		return app.Storage.Posts.GetByID(r.Context(), postID, getUserFromCtx(r).ID)
+	}, next)
+}
+
+func (app *Application) deletedPostContextMiddleWare(next http.Handler) http.Handler {
+	return app.loadPostContext(func(r *http.Request, postID int64) (*storage.Post, error) {
+		return app.Storage.Posts.GetDeletedByID(r.Context(), postID)
+	}, next)
+}
+
+func (app *Application) loadPostContext(getPost func(*http.Request, int64) (*storage.Post, error), next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
 		if err != nil {
@@ -55,7 +67,7 @@ func (app *Application) postContextMiddleWare(next http.Handler) http.Handler {
 		}
 
 		ctx := r.Context()
-		post, err := app.Storage.Posts.GetByID(ctx, postID)
+		post, err := getPost(r, postID)
 		if err != nil {
 			switch {
 			case errors.Is(err, storage.ErrNotFound):
@@ -140,14 +152,31 @@ func (app *Application) authMaiddleWare(next http.Handler) http.Handler {
 		userId, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
 		if err != nil {
 			app.internalServerError(w, r, err)
+			return
+		}
+		sessionID, ok := claims["sid"].(float64)
+		if !ok {
+			app.unAuthError(w, r, fmt.Errorf("token has no session"))
+			return
 		}
 		ctx := r.Context()
+		if err := app.Storage.Sessions.Touch(ctx, int64(sessionID), userId, clientIP(r)); err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				app.unAuthError(w, r, fmt.Errorf("session has been revoked"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
 		user, err := app.getUser(ctx, userId)
 		if err != nil {
 			app.internalServerError(w, r, err)
+			return
 		}
 
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, sessionCtx, int64(sessionID))
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 
@@ -165,12 +194,12 @@ func (app *Application) checkPermission(requiredRole string, next http.HandlerFu
 			return
 		}
 		//TODO : fix userInvitation
-		role, err := app.Storage.Roles.GetByName(r.Context(), requiredRole)
+		allowed, err := app.checkOwnerOrRole(r.Context(), user, post.UserID, requiredRole)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
 		}
-		if post.UserID != user.ID && user.Role_id < role.ID {
+		if !allowed {
 			app.forbiddenResponse(w, r)
 			return
 		}
@@ -178,6 +207,39 @@ func (app *Application) checkPermission(requiredRole string, next http.HandlerFu
 	})
 }
 
+func (app *Application) checkCommentPermission(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comment := getCommentFromCtx(r)
+		user := getUserFromCtx(r)
+		if user == nil || comment == nil {
+			app.internalServerError(w, r, fmt.Errorf("cannot get user or comment from context"))
+			return
+		}
+		allowed, err := app.checkOwnerOrRole(r.Context(), user, comment.UserID, requiredRole)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !allowed {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkOwnerOrRole reports whether the user owns the resource or has at least the required role.
+func (app *Application) checkOwnerOrRole(ctx context.Context, user *storage.User, ownerID int64, requiredRole string) (bool, error) {
+	if user.ID == ownerID {
+		return true, nil
+	}
+	role, err := app.Storage.Roles.GetByName(ctx, requiredRole)
+	if err != nil {
+		return false, err
+	}
+	return user.Role_id >= role.ID, nil
+}
+
 func (app *Application) getUser(ctx context.Context, userID int64) (*storage.User, error) {
 	if !app.Config.RedisConfig.Enabled {
 		return app.Storage.Users.GetByID(ctx, userID)
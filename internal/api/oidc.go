@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/dunkykorZhik/social/internal/oidc"
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxGeneratedUsername = 30
+	usernameAttempts     = 4
+	stateCookie          = "oidc_state"
+)
+
+var (
+	errUnverifiedEmail = errors.New("the provider did not share a verified email")
+	errInactiveEmail   = errors.New("an account with this email is not activated yet, activate it to log in with the provider")
+)
+
+// oidcLoginHandler godoc
+//
+//	@Summary		Starts a social login
+//	@Description	Redirects to the OpenID provider to log in with the authorization code flow and PKCE. The state is
+//	@Description	bound to the browser with a cookie, so the callback only completes the login in the browser that started it
+//	@Tags			authentication
+//	@Param			provider		path	string	true	"Provider name"
+//	@Param			device_label	query	string	false	"Label of the session"
+//	@Success		302
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/authentication/oidc/{provider} [get]
+func (app *Application) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.getOIDCProvider(w, r)
+	if !ok {
+		return
+	}
+	deviceLabel := r.URL.Query().Get("device_label")
+	if len(deviceLabel) > 100 {
+		app.badRequestReponse(w, r, errors.New("device_label is longer than 100 characters"))
+		return
+	}
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	state := &storage.OAuthState{
+		StateHash:    hashToken(req.State),
+		Provider:     provider.Name(),
+		Nonce:        req.Nonce,
+		CodeVerifier: req.Verifier,
+		DeviceLabel:  deviceLabel,
+	}
+	if err := app.Storage.Identities.CreateState(r.Context(), state, app.Config.OIDCConfig.StateExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	authURL, err := provider.AuthCodeURL(r.Context(), req)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state.StateHash,
+		Path:     stateCookiePath(provider),
+		MaxAge:   int(app.Config.OIDCConfig.StateExp.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallbackHandler godoc
+//
+//	@Summary		Completes a social login
+//	@Description	Redeems the code the OpenID provider redirected back with. The identity logs in its linked user, is
+//	@Description	linked to the user of its verified email or creates an activated user
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Param			code		query		string	true	"Authorization code"
+//	@Param			state		query		string	true	"State"
+//	@Success		201			{object}	TokenResponse
+//	@Success		202			{object}	TwoFactorChallenge
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/callback [get]
+func (app *Application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := app.getOIDCProvider(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		app.unAuthError(w, r, errors.New("provider refused the login: "+e))
+		return
+	}
+	if q.Get("code") == "" || q.Get("state") == "" {
+		app.badRequestReponse(w, r, errors.New("code and state are required"))
+		return
+	}
+	// A login started in another browser, say by an attacker who sends their callback link, is refused.
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(hashToken(q.Get("state")))) != 1 {
+		app.unAuthError(w, r, errors.New("the login was started in another browser"))
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Path:     stateCookiePath(provider),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	state, err := app.Storage.Identities.ConsumeState(r.Context(), hashToken(q.Get("state")))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.unAuthError(w, r, errors.New("the login expired or was already completed"))
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if state.Provider != provider.Name() {
+		app.unAuthError(w, r, errors.New("the login was started at another provider"))
+		return
+	}
+
+	req := oidc.AuthRequest{State: q.Get("state"), Nonce: state.Nonce, Verifier: state.CodeVerifier}
+	identity, err := provider.Exchange(r.Context(), q.Get("code"), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrExchange), errors.Is(err, oidc.ErrInvalidIDToken):
+			app.unAuthError(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	user, err := app.identityUser(r.Context(), provider.Name(), identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errUnverifiedEmail):
+			app.badRequestReponse(w, r, err)
+			return
+		case errors.Is(err, errInactiveEmail), errors.Is(err, storage.ErrConflict):
+			app.conflictError(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.login(w, r, user, state.DeviceLabel)
+}
+
+// identityUser returns the user linked to the identity. An identity seen for the first time is linked to the user
+// of its email, or gets a new activated user, but only when the provider verified the email. The email of a user
+// who has not activated yet fails with errInactiveEmail.
+func (app *Application) identityUser(ctx context.Context, provider string, identity *oidc.Identity) (*storage.User, error) {
+	user, err := app.Storage.Identities.GetUser(ctx, provider, identity.Subject)
+	switch {
+	case err == nil:
+		return user, nil
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, err
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errUnverifiedEmail
+	}
+
+	user, err = app.Storage.Users.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if err := app.Storage.Identities.Link(ctx, user.ID, provider, identity.Subject, identity.Email); err != nil {
+			return nil, err
+		}
+		return user, nil
+	case !errors.Is(err, storage.ErrNotFound):
+		return nil, err
+	}
+
+	user = &storage.User{Email: identity.Email}
+	if len(identity.Name) <= 50 {
+		user.DisplayName = identity.Name
+	}
+	if len(identity.Picture) <= 500 && isProfileURL(identity.Picture) {
+		user.AvatarURL = identity.Picture
+	}
+	// The account has no usable password until the user sets one.
+	password, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := user.Password.Set(password); err != nil {
+		return nil, err
+	}
+
+	base := usernameFromIdentity(identity)
+	for attempt := range usernameAttempts {
+		user.Username = base
+		if attempt > 0 {
+			suffix, err := randomHex(2)
+			if err != nil {
+				return nil, err
+			}
+			user.Username = base + "_" + suffix
+		}
+		err = app.Storage.Identities.CreateUser(ctx, user, provider, identity.Subject)
+		if !errors.Is(err, storage.ErrConflict) {
+			break
+		}
+	}
+	switch {
+	case errors.Is(err, storage.ErrEmailTaken):
+		// GetByEmail only finds active users, the email belongs to a user who has not activated yet.
+		return nil, errInactiveEmail
+	case err != nil:
+		return nil, err
+	}
+	return user, nil
+}
+
+// getOIDCProvider looks up the provider of the path and writes the error response on failure.
+func (app *Application) getOIDCProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	provider, ok := app.OIDC[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundReponse(w, r, errors.New("unknown login provider"))
+		return nil, false
+	}
+	return provider, true
+}
+
+// stateCookiePath limits the state cookie to the callback of the provider.
+func stateCookiePath(provider *oidc.Provider) string {
+	return "/v1/authentication/oidc/" + provider.Name() + "/callback"
+}
+
+// usernameFromIdentity derives a mentionable username, see usernameRe, from the preferred username or the email.
+func usernameFromIdentity(identity *oidc.Identity) string {
+	name := identity.PreferredUsername
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	var b strings.Builder
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+			b.WriteRune(c)
+		case c == '.' || c == '-':
+			b.WriteRune('_')
+		}
+		if b.Len() == maxGeneratedUsername {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "user"
+	}
+	return b.String()
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
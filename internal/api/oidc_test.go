@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/oidc"
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestOIDCLogin(t *testing.T) {
+	app := newTestApplication(t)
+
+	provider, err := oidc.NewMockServer("social", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer provider.Close()
+	// The state cookie is Secure, the browser only returns it over TLS.
+	srv := httptest.NewTLSServer(app.Mount())
+	defer srv.Close()
+	browser := srv.Client()
+	browser.Jar, err = cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.OIDC = map[string]*oidc.Provider{
+		"mock": oidc.New(oidc.Config{
+			Name:         "mock",
+			Issuer:       provider.URL,
+			ClientID:     "social",
+			ClientSecret: "secret",
+			RedirectURL:  srv.URL + "/v1/authentication/oidc/mock/callback",
+		}, provider.Client()),
+	}
+
+	t.Run("should log in through the provider", func(t *testing.T) {
+		res, err := browser.Get(srv.URL + "/v1/authentication/oidc/mock?device_label=laptop")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		checkResponse(t, res.StatusCode, http.StatusCreated)
+	})
+	t.Run("should refuse an identity without a verified email", func(t *testing.T) {
+		provider.Identity.EmailVerified = false
+		defer func() { provider.Identity.EmailVerified = true }()
+		res, err := browser.Get(srv.URL + "/v1/authentication/oidc/mock")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		checkResponse(t, res.StatusCode, http.StatusBadRequest)
+	})
+	t.Run("should refuse the email of a user who has not activated", func(t *testing.T) {
+		provider.Identity.Email = storage.MockInactiveEmail
+		defer func() { provider.Identity.Email = "mock@example.com" }()
+		res, err := browser.Get(srv.URL + "/v1/authentication/oidc/mock")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		checkResponse(t, res.StatusCode, http.StatusConflict)
+		var body struct{ Error string }
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error != errInactiveEmail.Error() {
+			t.Errorf("Expected %q, but got %q", errInactiveEmail, body.Error)
+		}
+	})
+	t.Run("should refuse a callback in another browser", func(t *testing.T) {
+		other := &http.Client{Transport: browser.Transport}
+		res, err := other.Get(srv.URL + "/v1/authentication/oidc/mock")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		checkResponse(t, res.StatusCode, http.StatusUnauthorized)
+	})
+	t.Run("should refuse an unknown state", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/mock/callback?code=abc&state=forged", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, app.Mount())
+		checkResponse(t, rr.Code, http.StatusUnauthorized)
+	})
+	t.Run("should not know other providers", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/authentication/oidc/unknown", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, app.Mount())
+		checkResponse(t, rr.Code, http.StatusNotFound)
+	})
+}
+
+func TestUsernameFromIdentity(t *testing.T) {
+	cases := map[string]oidc.Identity{
+		"jane_doe":                       {PreferredUsername: "jane.doe"},
+		"gopher":                         {Email: "gopher@example.com"},
+		"user":                           {PreferredUsername: "ÄÖÜ"},
+		"a_b_c":                          {PreferredUsername: "a-b_c!"},
+		"xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx": {PreferredUsername: strings.Repeat("x", 40)},
+	}
+	for want, identity := range cases {
+		if got := usernameFromIdentity(&identity); got != want {
+			t.Errorf("Expected %q, but got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type ReorderPinsPayLoad struct {
+	PostIDs []int64 `json:"post_ids" validate:"required,unique"`
+}
+
+// PinPost godoc
+//
+//	@Summary		Pins a post
+//	@Description	Pins one of the user's own published posts to the top of their posts, pinning twice has no effect
+//	@Tags			pins
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{object}	string
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Too many pinned posts"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [put]
+func (app *Application) pinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	if post.UserID != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	if !post.Published(time.Now()) {
+		app.badRequestReponse(w, r, errors.New("only published posts can be pinned"))
+		return
+	}
+
+	if err := app.Storage.Pins.Pin(r.Context(), post.ID, user.ID, app.Config.PostConfig.MaxPinned); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPinLimit):
+			app.conflictError(w, r, err)
+			return
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnpinPost godoc
+//
+//	@Summary		Unpins a post
+//	@Description	Unpins one of the user's own posts, unpinning a post that is not pinned has no effect
+//	@Tags			pins
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		204		{object}	string
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/pin [delete]
+func (app *Application) unpinPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	if post.UserID != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	if err := app.Storage.Pins.Unpin(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderPins godoc
+//
+//	@Summary		Reorders the pinned posts
+//	@Description	Arranges the pinned posts of the user, the payload lists every pinned post once in the new order
+//	@Tags			pins
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ReorderPinsPayLoad	true	"Pinned post IDs"
+//	@Success		204		{object}	string
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/pins [put]
+func (app *Application) reorderPinsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ReorderPinsPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	if err := app.Storage.Pins.Reorder(r.Context(), user.ID, payload.PostIDs); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrPinOrder):
+			app.badRequestReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserPosts godoc
+//
+//	@Summary		Fetches the posts of a user
+//	@Description	Fetches the posts of a user readable by the viewer, pinned posts first
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]storage.PostForFeed
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/posts [get]
+func (app *Application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	pq, ok := app.readPaginateQuery(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := app.Storage.Posts.GetByUserID(r.Context(), userID, getUserFromCtx(r).ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.prepareFeed(posts, getUserFromCtx(r).ID)
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestPins(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should pin an own post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/pin", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should unpin a post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/pin", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should reorder the pinned posts", func(t *testing.T) {
+		for body, code := range map[string]int{
+			`{"post_ids":[2,1]}`: http.StatusNoContent,
+			`{"post_ids":[1,1]}`: http.StatusBadRequest,
+			`{"post_ids":[1,3]}`: http.StatusBadRequest,
+			`{"post_ids":[1]}`:   http.StatusBadRequest,
+		} {
+			req, err := http.NewRequest(http.MethodPut, "/v1/users/me/pins", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, code)
+		}
+	})
+	t.Run("should list the posts of a user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1/posts", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should not let another user pin or unpin a post", func(t *testing.T) {
+		app := newTestApplication(t)
+		asViewer(app, storage.User{Role_id: 4})
+		mux := app.Mount()
+		for _, method := range []string{http.MethodPut, http.MethodDelete} {
+			req, err := http.NewRequest(method, "/v1/posts/1/pin", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, http.StatusForbidden)
+		}
+	})
+	t.Run("should only pin published posts", func(t *testing.T) {
+		app := newTestApplication(t)
+		asViewer(app, storage.User{Role_id: 2})
+		past := time.Now().Add(-time.Hour)
+		future := time.Now().Add(time.Hour)
+		app.Storage.Posts = &visibilityMockStorage{posts: map[int64]storage.Post{
+			1: {ID: 1, UserID: 1, Status: storage.PostStatusScheduled, PublishAt: &past},
+			2: {ID: 2, UserID: 1, Status: storage.PostStatusScheduled, PublishAt: &future},
+			3: {ID: 3, UserID: 1, Status: storage.PostStatusDraft},
+		}}
+		mux := app.Mount()
+		for postID, code := range map[int]int{1: http.StatusNoContent, 2: http.StatusBadRequest, 3: http.StatusBadRequest} {
+			req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("/v1/posts/%d/pin", postID), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, code)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+type CreatePollPayLoad struct {
+	Options        []string   `json:"options" validate:"required,min=2,max=10,unique,dive,required,max=100"`
+	MultipleChoice bool       `json:"multiple_choice"`
+	ClosesAt       *time.Time `json:"closes_at"`
+}
+
+type VotePollPayLoad struct {
+	OptionIDs []int64 `json:"option_ids" validate:"required,min=1,max=10,unique"`
+}
+
+// newPoll turns the payload into the poll saved along with a new post.
+func newPoll(payload *CreatePollPayLoad) (*storage.Poll, error) {
+	if payload.ClosesAt != nil && !payload.ClosesAt.After(time.Now()) {
+		return nil, errors.New("the poll must close in the future")
+	}
+	poll := &storage.Poll{
+		MultipleChoice: payload.MultipleChoice,
+		ClosesAt:       payload.ClosesAt,
+		Options:        make([]storage.PollOption, len(payload.Options)),
+		ViewerChoices:  []int64{},
+		TotalVoters:    new(int),
+		ResultsVisible: true,
+	}
+	for i, text := range payload.Options {
+		poll.Options[i] = storage.PollOption{Text: text, Votes: new(int)}
+	}
+	return poll, nil
+}
+
+// withPollVisibility hides the poll results from a viewer who has not voted yet while the poll is open,
+// unless the configuration shows them to everyone.
+func (app *Application) withPollVisibility(poll *storage.Poll) *storage.Poll {
+	if app.Config.PollConfig.HideResultsUntilVoted && !poll.Voted && !poll.Closed {
+		poll.HideResults()
+	}
+	return poll
+}
+
+// VotePoll godoc
+//
+//	@Summary		Votes in the poll of a post
+//	@Description	Records the caller's vote, single choice polls take exactly one option and votes are final
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		VotePollPayLoad	true	"Vote payload"
+//	@Success		200		{object}	storage.Poll
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/poll/votes [post]
+func (app *Application) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	var payload VotePollPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+	poll, ok := app.getPoll(w, r, post.ID, user.ID)
+	if !ok {
+		return
+	}
+
+	switch {
+	case poll.Closed:
+		app.badRequestReponse(w, r, storage.ErrPollClosed)
+		return
+	case poll.Voted:
+		app.conflictError(w, r, errors.New("already voted"))
+		return
+	case !poll.MultipleChoice && len(payload.OptionIDs) > 1:
+		app.badRequestReponse(w, r, errors.New("the poll allows a single choice"))
+		return
+	}
+	for _, id := range payload.OptionIDs {
+		if !slices.ContainsFunc(poll.Options, func(o storage.PollOption) bool { return o.ID == id }) {
+			app.badRequestReponse(w, r, fmt.Errorf("option %d does not belong to the poll", id))
+			return
+		}
+	}
+
+	if err := app.Storage.Polls.Vote(ctx, poll.ID, user.ID, payload.OptionIDs); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			app.conflictError(w, r, err)
+			return
+		case errors.Is(err, storage.ErrPollClosed), errors.Is(err, storage.ErrNotFound):
+			app.badRequestReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	poll, ok = app.getPoll(w, r, post.ID, user.ID)
+	if !ok {
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, app.withPollVisibility(poll)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getPoll loads the poll of the post and writes the error response on failure.
+func (app *Application) getPoll(w http.ResponseWriter, r *http.Request, postID, viewerID int64) (*storage.Poll, bool) {
+	poll, err := app.Storage.Polls.GetByPostID(r.Context(), postID, viewerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return nil, false
+		default:
+			app.internalServerError(w, r, err)
+			return nil, false
+		}
+	}
+	return poll, true
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestPolls(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should create a post with a poll", func(t *testing.T) {
+		body := `{"title":"t","content":"vote","poll":{"options":["yes","no"],"multiple_choice":false}}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+	})
+	t.Run("should reject polls with too few options", func(t *testing.T) {
+		body := `{"title":"t","content":"vote","poll":{"options":["yes"]}}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should hide the results until the caller votes", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+
+		var res struct {
+			Data storage.Post `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Data.Poll == nil || res.Data.Poll.ResultsVisible || res.Data.Poll.Options[0].Votes != nil {
+			t.Errorf("Expected hidden poll results, but got %+v", res.Data.Poll)
+		}
+	})
+	t.Run("should reject several choices in a single choice poll", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/poll/votes", strings.NewReader(`{"option_ids":[1,2]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should reject options of another poll", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/poll/votes", strings.NewReader(`{"option_ids":[7]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should vote", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/poll/votes", strings.NewReader(`{"option_ids":[1]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/dunkykorZhik/social/internal/storage"
 	"github.com/go-chi/chi/v5"
@@ -14,14 +15,25 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePostPayLoad struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=200"`
-	Tags    []string `json:"tags"`
+	Title       string             `json:"title" validate:"required,max=100"`
+	Content     string             `json:"content" validate:"required"`
+	Format      string             `json:"format" validate:"omitempty,oneof=plain markdown"`
+	Tags        []string           `json:"tags" validate:"max=10"`
+	Draft       bool               `json:"draft"`
+	PublishAt   *time.Time         `json:"publish_at"`
+	Visibility  string             `json:"visibility" validate:"omitempty,oneof=public followers private"`
+	QuotePostID *int64             `json:"quote_post_id" validate:"omitempty,gte=1"`
+	Poll        *CreatePollPayLoad `json:"poll"`
 }
 
 type UpdatePostPayLoad struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=200"`
+	Title      *string    `json:"title" validate:"omitempty,max=100"`
+	Content    *string    `json:"content"`
+	Format     *string    `json:"format" validate:"omitempty,oneof=plain markdown"`
+	Tags       *[]string  `json:"tags" validate:"omitempty,max=10"`
+	Draft      *bool      `json:"draft"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Visibility *string    `json:"visibility" validate:"omitempty,oneof=public followers private"`
 }
 
 // CreatePost godoc
@@ -49,17 +61,61 @@ func (app *Application) createPostHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestReponse(w, r, err)
 		return
 	}
-	post := &storage.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  1,
+	format := contentFormat(payload.Format)
+	if err := app.checkContentLength(payload.Content, format); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	tags, err := postTags(payload.Tags, payload.Content)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
 	}
+	user := getUserFromCtx(r)
 	ctx := r.Context()
+	if payload.QuotePostID != nil {
+		if _, err := app.Storage.Posts.GetByID(ctx, *payload.QuotePostID, user.ID); err != nil {
+			switch {
+			case errors.Is(err, storage.ErrNotFound):
+				app.badRequestReponse(w, r, errors.New("quoted post not found"))
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+	}
+	var poll *storage.Poll
+	if payload.Poll != nil {
+		poll, err = newPoll(payload.Poll)
+		if err != nil {
+			app.badRequestReponse(w, r, err)
+			return
+		}
+	}
+	post := &storage.Post{
+		Title:     payload.Title,
+		Content:   payload.Content,
+		Format:    format,
+		Tags:      tags,
+		UserID:    user.ID,
+		Status:    postStatus(payload.Draft, payload.PublishAt),
+		PublishAt: payload.PublishAt,
+		Mentions:  extractMentions(payload.Content),
+
+		Visibility:   postVisibility(payload.Visibility),
+		QuotedPostID: payload.QuotePostID,
+		Poll:         poll,
+	}
 	if err := app.Storage.Posts.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.queueLinkPreview(post)
+	if post.Poll != nil {
+		app.withPollVisibility(post.Poll)
+	}
+	renderPost(post)
 	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -83,12 +139,50 @@ func (app *Application) createPostHandler(w http.ResponseWriter, r *http.Request
 func (app *Application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.Storage.Comments.GetByPostID(r.Context(), post.ID)
+	page, err := app.Storage.Comments.GetPageByPostID(r.Context(), post.ID, defaultCommentQuery)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Comments = page.Comments
+	post.CommentCount = page.Total
+	post.CommentsNextCursor = page.NextCursor
+
+	reactions, err := app.Storage.Reactions.GetByPostID(r.Context(), post.ID, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	post.Reactions = reactions
+
+	attachments, err := app.Storage.Attachments.GetByPostID(r.Context(), post.ID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
-	post.Comments = comments
+	post.Attachments = app.withAttachmentURLs(attachments, getUserFromCtx(r).ID)
+
+	poll, err := app.Storage.Polls.GetByPostID(r.Context(), post.ID, getUserFromCtx(r).ID)
+	switch {
+	case err == nil:
+		post.Poll = app.withPollVisibility(poll)
+	case !errors.Is(err, storage.ErrNotFound):
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if post.QuotedPostID != nil {
+		quoted, err := app.Storage.Posts.GetByID(r.Context(), *post.QuotedPostID, getUserFromCtx(r).ID)
+		switch {
+		case err == nil:
+			renderPost(quoted)
+			post.QuotedPost = quoted
+		case !errors.Is(err, storage.ErrNotFound):
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	renderPost(post)
 
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -136,6 +230,40 @@ func (app *Application) deletePostHandler(w http.ResponseWriter, r *http.Request
 
 }
 
+// RestorePost godoc
+//
+//	@Summary		Restores a deleted post
+//	@Description	Takes a post out of the trash before it is purged
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Post ID"
+//	@Success		200	{object}	storage.Post
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{id}/restore [post]
+func (app *Application) restorePostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	if err := app.Storage.Posts.Restore(r.Context(), post.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	renderPost(post)
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // UpdatePost godoc
 //
 //	@Summary		Updates a post
@@ -164,13 +292,44 @@ func (app *Application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	post := getPostFromCtx(r)
+	tags := withoutHashtags(post.Tags, post.Content)
+	if payload.Tags != nil {
+		tags = *payload.Tags
+	}
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
 	if payload.Title != nil {
 		post.Title = *payload.Title
 	}
-	err := app.Storage.Posts.Update(r.Context(), post)
+	if payload.Format != nil {
+		post.Format = *payload.Format
+	}
+	post.Format = contentFormat(post.Format)
+	if err := app.checkContentLength(post.Content, post.Format); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	post.Mentions = extractMentions(post.Content)
+	tags, err := postTags(tags, post.Content)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	post.Tags = tags
+	draft := post.Status == storage.PostStatusDraft
+	if payload.Draft != nil {
+		draft = *payload.Draft
+	}
+	if payload.PublishAt != nil {
+		post.PublishAt = payload.PublishAt
+	}
+	post.Status = postStatus(draft, post.PublishAt)
+	if payload.Visibility != nil {
+		post.Visibility = *payload.Visibility
+	}
+	post.Visibility = postVisibility(post.Visibility)
+	err = app.Storage.Posts.Update(r.Context(), post)
 	if err != nil {
 		switch {
 		case errors.Is(err, storage.ErrNotFound):
@@ -181,17 +340,39 @@ func (app *Application) updatePostHandler(w http.ResponseWriter, r *http.Request
 			return
 		}
 	}
+	app.queueLinkPreview(post)
 	if err := app.CacheStorage.Users.Delete(r.Context(), post.UserID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	renderPost(post)
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
 	}
 
 }
 
+// postStatus tells whether a post is kept as a draft, waits for its publish time or is readable right away.
+func postStatus(draft bool, publishAt *time.Time) string {
+	switch {
+	case draft:
+		return storage.PostStatusDraft
+	case publishAt != nil && publishAt.After(time.Now()):
+		return storage.PostStatusScheduled
+	default:
+		return storage.PostStatusPublished
+	}
+}
+
+// postVisibility defaults the visibility of a post to public.
+func postVisibility(visibility string) string {
+	if visibility == "" {
+		return storage.PostVisibilityPublic
+	}
+	return visibility
+}
+
 func getPostFromCtx(r *http.Request) *storage.Post {
 	post := r.Context().Value(postCtx).(*storage.Post)
 	return post
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestPosts(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should create a draft post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(`{"title":"t","content":"c","draft":true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+		if !strings.Contains(rr.Body.String(), `"status":"draft"`) {
+			t.Errorf("Expected a draft post, but got %s", rr.Body.String())
+		}
+	})
+	t.Run("should render markdown content to sanitized html", func(t *testing.T) {
+		body := `{"title":"t","content":"**hi** <script>x</script> [a](javascript:alert(1))","format":"markdown"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+		var res struct {
+			Data storage.Post `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if want := "<p><strong>hi</strong> &lt;script&gt;x&lt;/script&gt; a</p>\n"; res.Data.ContentHTML != want {
+			t.Errorf("Expected %q, but got %q", want, res.Data.ContentHTML)
+		}
+	})
+	t.Run("should limit content length per format", func(t *testing.T) {
+		content := strings.Repeat("a", 300)
+		for format, code := range map[string]int{"plain": http.StatusBadRequest, "markdown": http.StatusCreated} {
+			body := `{"title":"t","content":"` + content + `","format":"` + format + `"}`
+			req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, code)
+		}
+	})
+	t.Run("should set the visibility of a post", func(t *testing.T) {
+		for body, code := range map[string]int{
+			`{"title":"t","content":"c"}`:                          http.StatusCreated,
+			`{"title":"t","content":"c","visibility":"followers"}`: http.StatusCreated,
+			`{"title":"t","content":"c","visibility":"friends"}`:   http.StatusBadRequest,
+		} {
+			req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, code)
+			if code == http.StatusCreated && !strings.Contains(body, "visibility") &&
+				!strings.Contains(rr.Body.String(), `"visibility":"public"`) {
+				t.Errorf("Expected a public post, but got %s", rr.Body.String())
+			}
+		}
+	})
+	t.Run("should allow the owner to delete a post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should allow the owner to restore a deleted post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+}
+
+// visibilityMockStorage serves posts by the rules of the visibleTo query: their authors see them, everyone else
+// only the public ones and the followers only ones of the authors they follow.
+type visibilityMockStorage struct {
+	storage.PostMockStorage
+	posts map[int64]storage.Post
+	// followers lists the followers of each author.
+	followers map[int64][]int64
+}
+
+func (p *visibilityMockStorage) GetByID(ctx context.Context, postID int64, viewerID int64) (*storage.Post, error) {
+	post, ok := p.posts[postID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	switch {
+	case post.UserID == viewerID, post.Visibility == storage.PostVisibilityPublic:
+	case post.Visibility == storage.PostVisibilityFollowers && slices.Contains(p.followers[post.UserID], viewerID):
+	default:
+		return nil, storage.ErrNotFound
+	}
+
+	return &post, nil
+}
+
+func TestPostVisibility(t *testing.T) {
+	app := newTestApplication(t)
+	// the test token is user 1, the other posts belong to user 7 and user 8, user 1 only follows user 8
+	asViewer(app, storage.User{Role_id: 2})
+	app.Storage.Posts = &visibilityMockStorage{
+		posts: map[int64]storage.Post{
+			1: {ID: 1, UserID: 7, Status: storage.PostStatusPublished, Visibility: storage.PostVisibilityPublic},
+			2: {ID: 2, UserID: 7, Status: storage.PostStatusPublished, Visibility: storage.PostVisibilityFollowers},
+			3: {ID: 3, UserID: 7, Status: storage.PostStatusPublished, Visibility: storage.PostVisibilityPrivate},
+			4: {ID: 4, UserID: 8, Status: storage.PostStatusPublished, Visibility: storage.PostVisibilityFollowers},
+			5: {ID: 5, UserID: 1, Status: storage.PostStatusPublished, Visibility: storage.PostVisibilityPrivate},
+		},
+		followers: map[int64][]int64{8: {1}},
+	}
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	tests := []struct {
+		name   string
+		postID int
+		status int
+	}{
+		{"public post", 1, http.StatusOK},
+		{"followers only post of an unfollowed user", 2, http.StatusNotFound},
+		{"private post of another user", 3, http.StatusNotFound},
+		{"followers only post of a followed user", 4, http.StatusOK},
+		{"own private post", 5, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := []struct {
+				method, path string
+				status       int
+			}{
+				{http.MethodGet, "/v1/posts/%d", tt.status},
+				{http.MethodGet, "/v1/posts/%d/comments", tt.status},
+				{http.MethodPut, "/v1/posts/%d/repost", tt.status},
+			}
+			for _, r := range requests {
+				if r.method == http.MethodPut && tt.status == http.StatusOK {
+					r.status = http.StatusNoContent
+				}
+				req, err := http.NewRequest(r.method, fmt.Sprintf(r.path, tt.postID), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+testToken)
+				rr := executeRequest(req, mux)
+				if rr.Code != r.status {
+					t.Errorf("%s %s: expected %d, but got %d", r.method, req.URL.Path, r.status, rr.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/entities"
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+// previewJob asks the worker to show the preview of url on the post, an empty url removes the preview.
+type previewJob struct {
+	postID int64
+	url    string
+}
+
+// queueLinkPreview hands the first link of the post content to the preview worker when it differs from the link
+// of the current preview. The request never waits for the page, a full queue drops the job.
+func (app *Application) queueLinkPreview(post *storage.Post) {
+	if app.previews == nil {
+		return
+	}
+	job := previewJob{postID: post.ID}
+	if links := entities.URLs(post.Content); len(links) > 0 {
+		job.url = links[0].Text
+	}
+	current := ""
+	if post.LinkPreview != nil {
+		current = post.LinkPreview.URL
+	}
+	if job.url == current {
+		return
+	}
+
+	select {
+	case app.previews <- job:
+	default:
+		app.Logger.Warnw("link preview queue is full", "post_id", job.postID, "url", job.url)
+	}
+}
+
+// runPreviewWorker unfurls the queued links one at a time, so the jobs of a post are applied in order.
+func (app *Application) runPreviewWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-app.previews:
+			if err := app.unfurlPost(ctx, job); err != nil {
+				app.Logger.Warnw("link preview failed", "post_id", job.postID, "url", job.url, "error", err.Error())
+			}
+		}
+	}
+}
+
+// unfurlPost attaches the preview of the job's link to the post, fetching the page unless a fresh preview is
+// cached. A link that cannot be unfurled leaves the post without a preview.
+func (app *Application) unfurlPost(ctx context.Context, job previewJob) error {
+	if job.url == "" {
+		return app.Storage.LinkPreviews.Attach(ctx, job.postID, "")
+	}
+
+	fetchedAfter := time.Now().Add(-app.Config.PreviewConfig.CacheTTL)
+	_, err := app.Storage.LinkPreviews.GetByURL(ctx, job.url, fetchedAfter)
+	switch {
+	case err == nil:
+		return app.Storage.LinkPreviews.Attach(ctx, job.postID, job.url)
+	case !errors.Is(err, storage.ErrNotFound):
+		return err
+	}
+
+	fetched, err := app.Unfurler.Fetch(ctx, job.url)
+	if err != nil {
+		return errors.Join(err, app.Storage.LinkPreviews.Attach(ctx, job.postID, ""))
+	}
+	preview := &storage.LinkPreview{
+		URL:         fetched.URL,
+		Title:       fetched.Title,
+		Description: fetched.Description,
+		ImageURL:    fetched.ImageURL,
+		SiteName:    fetched.SiteName,
+	}
+	if err := app.Storage.LinkPreviews.Save(ctx, preview); err != nil {
+		return err
+	}
+	return app.Storage.LinkPreviews.Attach(ctx, job.postID, job.url)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/dunkykorZhik/social/internal/unfurl"
+)
+
+func TestLinkPreviews(t *testing.T) {
+	app := newTestApplication(t)
+	app.previews = make(chan previewJob, 1)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should queue the first link of a new post", func(t *testing.T) {
+		body := `{"title":"t","content":"read https://example.com/a, then https://example.com/b"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+		select {
+		case job := <-app.previews:
+			if job.url != "https://example.com/a" {
+				t.Errorf("Expected the first link, but got %q", job.url)
+			}
+		default:
+			t.Error("Expected a queued link preview")
+		}
+	})
+	t.Run("should not queue an unchanged link", func(t *testing.T) {
+		post := &storage.Post{
+			Content:     "see https://example.com/a",
+			LinkPreview: &storage.LinkPreview{URL: "https://example.com/a"},
+		}
+		app.queueLinkPreview(post)
+		if len(app.previews) != 0 {
+			t.Error("Expected no queued link preview")
+		}
+	})
+	t.Run("should unfurl a queued link", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<head><meta property="og:title" content="Example"></head>`))
+		}))
+		defer srv.Close()
+		app.Unfurler = unfurl.New(unfurl.Config{Timeout: time.Second, MaxBodySize: 1 << 10, AllowPrivateNetworks: true})
+
+		if err := app.unfurlPost(context.Background(), previewJob{postID: 1, url: srv.URL}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
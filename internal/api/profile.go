@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+// usernameRe keeps new usernames mentionable, see entities.Mentions.
+var usernameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+type UpdateProfilePayLoad struct {
+	Username    *string `json:"username" validate:"omitempty,min=1,max=100"`
+	DisplayName *string `json:"display_name" validate:"omitempty,max=50"`
+	Bio         *string `json:"bio" validate:"omitempty,max=300"`
+	Website     *string `json:"website" validate:"omitempty,max=200"`
+	Location    *string `json:"location" validate:"omitempty,max=100"`
+	AvatarURL   *string `json:"avatar_url" validate:"omitempty,max=500"`
+}
+
+// GetMe godoc
+//
+//	@Summary		Fetches the current user
+//	@Description	Fetches the profile of the authenticated user with its follower, following and post counts
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	storage.User
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [get]
+func (app *Application) getMeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	user, err := app.withUserStats(r.Context(), user, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateMe godoc
+//
+//	@Summary		Updates the current user
+//	@Description	Updates the profile of the authenticated user, the username can only be changed once per cooldown
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateProfilePayLoad	true	"Profile payload"
+//	@Success		200		{object}	storage.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Username taken"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [patch]
+func (app *Application) updateMeHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateProfilePayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	user := *getUserFromCtx(r)
+	if payload.Username != nil && *payload.Username != user.Username {
+		if !usernameRe.MatchString(*payload.Username) {
+			app.badRequestReponse(w, r, errors.New("username can only contain letters, digits and underscores"))
+			return
+		}
+		user.Username = *payload.Username
+	}
+	if payload.DisplayName != nil {
+		user.DisplayName = strings.TrimSpace(*payload.DisplayName)
+	}
+	if payload.Bio != nil {
+		user.Bio = strings.TrimSpace(*payload.Bio)
+	}
+	if payload.Location != nil {
+		user.Location = strings.TrimSpace(*payload.Location)
+	}
+	if payload.Website != nil {
+		if !isProfileURL(*payload.Website) {
+			app.badRequestReponse(w, r, errors.New("website must be an http or https URL"))
+			return
+		}
+		user.Website = *payload.Website
+	}
+	if payload.AvatarURL != nil {
+		if !isProfileURL(*payload.AvatarURL) {
+			app.badRequestReponse(w, r, errors.New("avatar_url must be an http or https URL"))
+			return
+		}
+		user.AvatarURL = *payload.AvatarURL
+	}
+
+	cooldown := app.Config.ProfileConfig.UsernameCooldown
+	if err := app.Storage.Users.UpdateProfile(r.Context(), &user, cooldown); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUsernameCooldown):
+			next := user.UsernameChangedAt.Add(cooldown)
+			app.badRequestReponse(w, r, fmt.Errorf("username can be changed again after %s", next.Format(time.RFC3339)))
+			return
+		case errors.Is(err, storage.ErrConflict):
+			app.conflictError(w, r, errors.New("username is taken"))
+			return
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := app.CacheStorage.Users.Delete(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// isProfileURL accepts an empty value, which clears the field, or an absolute http or https URL.
+func isProfileURL(value string) bool {
+	if value == "" {
+		return true
+	}
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
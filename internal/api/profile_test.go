@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestProfile(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should fetch the current user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should update the profile", func(t *testing.T) {
+		body := `{"username":"new_name","display_name":" New Name ","bio":"hi","website":"https://example.com"}`
+		req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+		if !strings.Contains(rr.Body.String(), `"display_name":"New Name"`) {
+			t.Errorf("Expected the trimmed display name, but got %s", rr.Body.String())
+		}
+	})
+	t.Run("should validate the profile", func(t *testing.T) {
+		for _, body := range []string{
+			`{"username":"has space"}`,
+			`{"website":"javascript:alert(1)"}`,
+			`{"avatar_url":"/relative.png"}`,
+			`{"bio":"` + strings.Repeat("a", 301) + `"}`,
+		} {
+			req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+// cooldownMockStorage rejects every profile update with a username changed a minute ago.
+type cooldownMockStorage struct {
+	storage.UserMockStorage
+}
+
+func (u *cooldownMockStorage) UpdateProfile(ctx context.Context, user *storage.User, cooldown time.Duration) error {
+	changedAt := time.Now().Add(-time.Minute)
+	user.UsernameChangedAt = &changedAt
+
+	return storage.ErrUsernameCooldown
+}
+
+func TestUsernameCooldown(t *testing.T) {
+	app := newTestApplication(t)
+	app.Storage.Users = &cooldownMockStorage{}
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(`{"username":"another_name"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponse(t, rr.Code, http.StatusBadRequest)
+	if !strings.Contains(rr.Body.String(), "username can be changed again after") {
+		t.Errorf("Expected the cooldown error, but got %s", rr.Body.String())
+	}
+}
+
+// takenMockStorage rejects every profile update as if the username belonged to another user.
+type takenMockStorage struct {
+	storage.UserMockStorage
+}
+
+func (u *takenMockStorage) UpdateProfile(ctx context.Context, user *storage.User, cooldown time.Duration) error {
+	return storage.ErrConflict
+}
+
+func TestUsernameTaken(t *testing.T) {
+	app := newTestApplication(t)
+	app.Storage.Users = &takenMockStorage{}
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	req, err := http.NewRequest(http.MethodPatch, "/v1/users/me", strings.NewReader(`{"username":"another_name"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponse(t, rr.Code, http.StatusConflict)
+	if !strings.Contains(rr.Body.String(), "username is taken") {
+		t.Errorf("Expected the conflict to be explained, but got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// AddReaction godoc
+//
+//	@Summary		Reacts to a post
+//	@Description	Adds a reaction of the given kind, reacting twice has no effect
+//	@Tags			reactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"	Enums(like, love, laugh, wow, sad, angry)
+//	@Success		200		{object}	storage.ReactionSummary
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [put]
+func (app *Application) addReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	if !storage.IsReactionKind(kind) {
+		app.badRequestReponse(w, r, fmt.Errorf("unknown reaction kind %q", kind))
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	ctx := r.Context()
+	if err := app.Storage.Reactions.Add(ctx, post.ID, user.ID, kind); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	app.reactionSummaryResponse(w, r, post.ID, user.ID)
+}
+
+// RemoveReaction godoc
+//
+//	@Summary		Removes a reaction from a post
+//	@Description	Removes a reaction of the given kind, removing a missing reaction has no effect
+//	@Tags			reactions
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			kind	path		string	true	"Reaction kind"	Enums(like, love, laugh, wow, sad, angry)
+//	@Success		200		{object}	storage.ReactionSummary
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions/{kind} [delete]
+func (app *Application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	if !storage.IsReactionKind(kind) {
+		app.badRequestReponse(w, r, fmt.Errorf("unknown reaction kind %q", kind))
+		return
+	}
+
+	post := getPostFromCtx(r)
+	user := getUserFromCtx(r)
+	if err := app.Storage.Reactions.Remove(r.Context(), post.ID, user.ID, kind); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.reactionSummaryResponse(w, r, post.ID, user.ID)
+}
+
+func (app *Application) reactionSummaryResponse(w http.ResponseWriter, r *http.Request, postID, userID int64) {
+	summary, err := app.Storage.Reactions.GetByPostID(r.Context(), postID, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, summary); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReactions(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should react to a post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/reactions/like", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should reject an unknown reaction kind", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/reactions/meh", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should remove a reaction", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/reactions/like", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+}
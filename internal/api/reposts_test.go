@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReposts(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should repost a post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/repost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should undo a repost", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/posts/1/repost", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should create a quote post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(`{"title":"t","content":"so true","quote_post_id":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+		if !strings.Contains(rr.Body.String(), `"quoted_post_id":1`) {
+			t.Errorf("Expected a quote of post 1, but got %s", rr.Body.String())
+		}
+	})
+}
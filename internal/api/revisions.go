@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dunkykorZhik/social/internal/diff"
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type RevisionDiff struct {
+	Revision    storage.PostRevision `json:"revision"`
+	TitleDiff   []diff.Op            `json:"title_diff"`
+	ContentDiff []diff.Op            `json:"content_diff"`
+}
+
+// ListRevisions godoc
+//
+//	@Summary		Fetches the revisions of a post
+//	@Description	Fetches the previous versions of a post, newest first
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]storage.PostRevision
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions [get]
+func (app *Application) listRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+
+	revisions, err := app.Storage.Revisions.GetByPostID(r.Context(), post.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, revisions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetRevision godoc
+//
+//	@Summary		Fetches a revision of a post
+//	@Description	Fetches a previous version of a post with its changes up to the current version
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version"
+//	@Success		200		{object}	RevisionDiff
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version} [get]
+func (app *Application) getRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	revision, ok := app.getRevision(w, r, post.ID)
+	if !ok {
+		return
+	}
+
+	data := RevisionDiff{
+		Revision:    *revision,
+		TitleDiff:   diff.Words(revision.Title, post.Title),
+		ContentDiff: diff.Words(revision.Content, post.Content),
+	}
+	if err := app.jsonResponse(w, http.StatusOK, data); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestoreRevision godoc
+//
+//	@Summary		Restores a revision of a post
+//	@Description	Saves the title and content of a previous version as a new version of the post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			version	path		int	true	"Version"
+//	@Success		200		{object}	storage.Post
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/revisions/{version}/restore [post]
+func (app *Application) restoreRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	revision, ok := app.getRevision(w, r, post.ID)
+	if !ok {
+		return
+	}
+
+	tags, err := postTags(withoutHashtags(post.Tags, post.Content), revision.Content)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Tags = tags
+	post.Mentions = extractMentions(post.Content)
+	if err := app.Storage.Posts.Update(r.Context(), post); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	app.queueLinkPreview(post)
+
+	renderPost(post)
+	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getRevision loads the revision named by the version URL param and writes the error response on failure.
+func (app *Application) getRevision(w http.ResponseWriter, r *http.Request, postID int64) (*storage.PostRevision, bool) {
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return nil, false
+	}
+
+	revision, err := app.Storage.Revisions.GetByVersion(r.Context(), postID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return revision, true
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestRevisions(t *testing.T) {
+	app := newTestApplication(t)
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should list the revisions of a post", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/revisions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should return a revision with its diff", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/revisions/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+
+		var body struct {
+			Data RevisionDiff `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data.Revision.Version != 1 || len(body.Data.ContentDiff) == 0 {
+			t.Errorf("unexpected revision %+v", body.Data)
+		}
+	})
+	t.Run("should return 404 for an unknown version", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/revisions/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNotFound)
+	})
+	t.Run("should return 400 for an invalid version", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/revisions/first", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should let the owner restore a revision", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/revisions/1/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+
+		var body struct {
+			Data storage.Post `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data.Title != "old title" || body.Data.Content != "old content" {
+			t.Errorf("expected the revision to be restored, got %q %q", body.Data.Title, body.Data.Content)
+		}
+	})
+	t.Run("should not let another user restore a revision", func(t *testing.T) {
+		asViewer(app, storage.User{Role_id: 3})
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/revisions/1/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusForbidden)
+	})
+}
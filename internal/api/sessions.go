@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+type sessionKey string
+
+const sessionCtx sessionKey = "session"
+
+// maxUserAgentLength matches the user_agent column of the sessions.
+const maxUserAgentLength = 500
+
+// ListSessions godoc
+//
+//	@Summary		Lists the sessions of the current user
+//	@Description	Lists the devices the authenticated user is logged in on, the most recently seen first. The session of the request is marked as current
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]storage.Session
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *Application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	sessions, err := app.Storage.Sessions.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	current := getSessionIDFromCtx(r)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, sessions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revokes a session of the current user
+//	@Description	Logs the authenticated user out on a device, its access and refresh tokens stop working right away
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			sessionID	path	int	true	"Session ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *Application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	if err := app.Storage.Sessions.Revoke(r.Context(), sessionID, user.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getSessionIDFromCtx(r *http.Request) int64 {
+	sessionID, _ := r.Context().Value(sessionCtx).(int64)
+	return sessionID
+}
+
+// clientIP is the address of the client as set by middleware.RealIP, without the port of a direct connection.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func userAgent(r *http.Request) string {
+	ua := r.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = strings.ToValidUTF8(ua[:maxUserAgentLength], "")
+	}
+	return ua
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSessions(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should list the sessions and mark the current one", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+		if strings.Count(rr.Body.String(), `"current":true`) != 1 {
+			t.Errorf("Expected one current session, but got %s", rr.Body.String())
+		}
+	})
+	t.Run("should revoke a session", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me/sessions/2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should not revoke an unknown session", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me/sessions/3", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNotFound)
+	})
+	t.Run("should refuse the access tokens of a revoked session", func(t *testing.T) {
+		// the test token belongs to session 1
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me/sessions/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+
+		req, err = http.NewRequest(http.MethodGet, "/v1/users/me/sessions", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr = executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusUnauthorized)
+	})
+}
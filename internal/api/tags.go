@@ -0,0 +1,142 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/entities"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPostTags caps the tags of a post, the ones sent by the client and the hashtags of its content together.
+const maxPostTags = 10
+
+// normalizeTags normalizes and deduplicates the tags, any tag entities.NormalizeTag refuses is an error.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		t, ok := entities.NormalizeTag(tag)
+		if !ok {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		if !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized, nil
+}
+
+// postTags combines the tags sent by the client with the hashtags found in the content.
+func postTags(tags []string, content string) ([]string, error) {
+	for _, hashtag := range entities.Hashtags(content) {
+		tags = append(tags, hashtag.Text)
+	}
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(normalized) > maxPostTags {
+		return nil, fmt.Errorf("a post can carry at most %d tags", maxPostTags)
+	}
+	return normalized, nil
+}
+
+// withoutHashtags drops the tags that came from the hashtags of the content, leaving the ones the client set.
+func withoutHashtags(tags []string, content string) []string {
+	hashtags := map[string]bool{}
+	for _, hashtag := range entities.Hashtags(content) {
+		hashtags[hashtag.Text] = true
+	}
+	kept := []string{}
+	for _, tag := range tags {
+		if !hashtags[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetches the posts of a tag
+//	@Description	Fetches the published posts carrying the tag
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Param			tags	query		string	false	"Tags"
+//	@Param			search	query		string	false	"Search"
+//	@Success		200		{object}	[]storage.PostForFeed
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/{tag}/posts [get]
+func (app *Application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	tag, ok := entities.NormalizeTag(chi.URLParam(r, "tag"))
+	if !ok {
+		app.badRequestReponse(w, r, fmt.Errorf("invalid tag %q", chi.URLParam(r, "tag")))
+		return
+	}
+
+	pq, ok := app.readPaginateQuery(w, r)
+	if !ok {
+		return
+	}
+
+	posts, err := app.Storage.Tags.GetPosts(r.Context(), tag, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// the tag pages are public, their media links are signed for anonymous viewers
+	app.prepareFeed(posts, 0)
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTrendingTags godoc
+//
+//	@Summary		Fetches the trending tags
+//	@Description	Ranks the tags by the number of posts published within the trending window
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Success		200		{object}	[]storage.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/tags/trending [get]
+func (app *Application) getTrendingTagsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil {
+			app.badRequestReponse(w, r, err)
+			return
+		}
+	}
+	if limit < 1 || limit > 50 {
+		app.badRequestReponse(w, r, fmt.Errorf("limit must be between 1 and 50"))
+		return
+	}
+
+	since := time.Now().Add(-app.Config.TagConfig.TrendingWindow)
+	tags, err := app.Storage.Tags.GetTrending(r.Context(), since, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
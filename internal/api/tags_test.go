@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+)
+
+func TestTags(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should list the posts of a tag without authentication", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/tags/GoLang/posts?limit=5", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should reject an invalid tag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/tags/a-b/posts", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should list the trending tags", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/tags/trending?limit=20", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+	})
+	t.Run("should merge hashtags into the post tags", func(t *testing.T) {
+		body := `{"title":"t","content":"learning #Go and #sql","tags":["News","go"]}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+
+		var res struct {
+			Data storage.Post `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"news", "go", "sql"}
+		if !reflect.DeepEqual(res.Data.Tags, want) {
+			t.Errorf("Expected %v, but got %v", want, res.Data.Tags)
+		}
+	})
+	t.Run("should reject invalid tags", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts", strings.NewReader(`{"title":"t","content":"c","tags":["two words"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+}
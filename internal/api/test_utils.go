@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dunkykorZhik/social/internal/auth"
+	"github.com/dunkykorZhik/social/internal/blob"
 	"github.com/dunkykorZhik/social/internal/storage"
 	"github.com/dunkykorZhik/social/internal/storage/cache"
 	"go.uber.org/zap"
@@ -20,10 +23,50 @@ func newTestApplication(t *testing.T) *Application {
 	mockAuth := auth.NewMockAuthenticator()
 
 	return &Application{
+		Config: Config{
+			AuthConfig: AuthConfig{
+				Token: TokenConfig{
+					Exp:        time.Minute,
+					RefreshExp: time.Hour,
+				},
+			},
+			PostConfig: PostConfig{
+				MaxPinned: 3,
+			},
+			MediaConfig: MediaConfig{
+				MaxFileSize:    1 << 20,
+				MaxAttachments: 4,
+				URLSecret:      "test",
+				URLExp:         time.Hour,
+			},
+			ContentConfig: ContentConfig{
+				PlainMaxLength:    200,
+				MarkdownMaxLength: 2000,
+			},
+			TagConfig: TagConfig{
+				TrendingWindow: time.Hour,
+			},
+			PollConfig: PollConfig{
+				HideResultsUntilVoted: true,
+			},
+			ProfileConfig: ProfileConfig{
+				UsernameCooldown: time.Hour,
+			},
+			OIDCConfig: OIDCConfig{
+				StateExp: time.Minute,
+			},
+			TwoFactorConfig: TwoFactorConfig{
+				Issuer:       "test",
+				ChallengeExp: time.Minute,
+				MaxAttempts:  3,
+				Lockout:      time.Minute,
+			},
+		},
 		Logger:       logger,
 		Storage:      mockStorage,
 		CacheStorage: mockCacheStorage,
 		Auth:         mockAuth,
+		Blob:         blob.NewMockStore(),
 	}
 
 }
@@ -40,3 +83,22 @@ func checkResponse(t *testing.T, res, expected int) {
 	}
 
 }
+
+// viewerMockStorage authenticates the test token as user, whose ID is taken from the token. The mock posts and
+// comments belong to user 0, so a viewer with another ID is not their owner.
+type viewerMockStorage struct {
+	storage.UserMockStorage
+	user storage.User
+}
+
+func (u *viewerMockStorage) GetByID(ctx context.Context, userID int64) (*storage.User, error) {
+	user := u.user
+	user.ID = userID
+
+	return &user, nil
+}
+
+// asViewer makes the requests of the test token come from user, see viewerMockStorage.
+func asViewer(app *Application, user storage.User) {
+	app.Storage.Users = &viewerMockStorage{user: user}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type RefreshTokenPayLoad struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=100"`
+}
+
+// TokenResponse is the token pair of a session. The access token authenticates requests until it expires after
+// ExpiresIn seconds, the refresh token can be exchanged once for the next pair.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// refreshTokenHandler godoc
+//
+//	@Summary		Refreshes a token
+//	@Description	Exchanges a refresh token for a new token pair. Each refresh token works once, presenting a used one revokes its session
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RefreshTokenPayLoad	true	"Refresh token"
+//	@Success		200		{object}	TokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *Application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	session, err := app.Storage.Sessions.Rotate(r.Context(), hashToken(payload.RefreshToken), hashToken(refreshToken),
+		clientIP(r), app.Config.AuthConfig.Token.RefreshExp)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.unAuthError(w, r, errors.New("refresh token is invalid or expired"))
+			return
+		case errors.Is(err, storage.ErrTokenReused):
+			app.Logger.Warnw("refresh token reused, session revoked", "method", r.Method, "path", r.URL.Path)
+			app.unAuthError(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	tokens, err := app.tokenPair(session, refreshToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logs out
+//	@Description	Revokes the session of the refresh token together with its access tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	RefreshTokenPayLoad	true	"Refresh token"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/authentication/logout [post]
+func (app *Application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RefreshTokenPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := app.Storage.Sessions.RevokeByToken(r.Context(), hashToken(payload.RefreshToken)); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.unAuthError(w, r, errors.New("refresh token is invalid"))
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession stores a new session of session.UserID and returns its first token pair.
+func (app *Application) startSession(ctx context.Context, session *storage.Session) (*TokenResponse, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := app.Storage.Sessions.Create(ctx, session, hashToken(refreshToken), app.Config.AuthConfig.Token.RefreshExp); err != nil {
+		return nil, err
+	}
+	return app.tokenPair(session, refreshToken)
+}
+
+// tokenPair signs an access token for the session and pairs it with the session's current refresh token.
+func (app *Application) tokenPair(session *storage.Session, refreshToken string) (*TokenResponse, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": session.UserID,
+		"sid": session.ID,
+		"exp": now.Add(app.Config.AuthConfig.Token.Exp).Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"iss": app.Config.AuthConfig.Token.Iss,
+		"aud": app.Config.AuthConfig.Token.Iss,
+	}
+
+	accessToken, err := app.Auth.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(app.Config.AuthConfig.Token.Exp.Seconds()),
+	}, nil
+}
+
+// newRefreshToken returns an opaque random token, only its hash is stored.
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
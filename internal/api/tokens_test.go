@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRefreshTokens(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	t.Run("should rotate the refresh token", func(t *testing.T) {
+		body := `{"refresh_token":"old"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+		if !strings.Contains(rr.Body.String(), `"token_type":"Bearer"`) || strings.Contains(rr.Body.String(), `"refresh_token":"old"`) {
+			t.Errorf("Expected a new token pair, but got %s", rr.Body.String())
+		}
+	})
+	t.Run("should refuse a refresh token that was already used", func(t *testing.T) {
+		body := `{"refresh_token":"old"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusUnauthorized)
+	})
+	t.Run("should require the refresh token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+	t.Run("should log out", func(t *testing.T) {
+		body := `{"refresh_token":"old"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+}
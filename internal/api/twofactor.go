@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/dunkykorZhik/social/internal/totp"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// challengeScope marks the tokens that only open the second login step. They are issued for their own
+	// audience, see challengeAudience, so neither authMaiddleWare nor the services verifying the published keys
+	// take them as access tokens.
+	challengeScope     = "2fa"
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TwoFactorCodePayLoad struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=20"`
+}
+
+type ConfirmTwoFactorPayLoad struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+type TwoFactorLoginPayLoad struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	TwoFactorCodePayLoad
+}
+
+// TwoFactorChallenge is the answer to a login of a user with two factor authentication, the challenge token and
+// a code are exchanged for the tokens at /authentication/token/2fa within ExpiresIn seconds.
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// EnrollTwoFactor godoc
+//
+//	@Summary		Starts two factor enrollment
+//	@Description	Creates a TOTP secret for the current user. It guards the login once it is confirmed with a code
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	TwoFactorEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error	"Already enabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa [post]
+func (app *Application) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromCtx(r)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.Storage.TwoFactor.Enroll(r.Context(), user.ID, secret); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrConflict):
+			app.conflictError(w, r, errors.New("two factor authentication is already enabled"))
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	enrollment := TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(app.Config.TwoFactorConfig.Issuer, user.Email, secret),
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmTwoFactor godoc
+//
+//	@Summary		Confirms two factor enrollment
+//	@Description	Enables two factor authentication with a code of the enrolled secret and returns the one-time recovery codes, which are not shown again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ConfirmTwoFactorPayLoad	true	"TOTP code"
+//	@Success		200		{object}	RecoveryCodes
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error	"Not enrolled"
+//	@Failure		409		{object}	error	"Already enabled"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa/confirm [post]
+func (app *Application) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ConfirmTwoFactorPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	tf, ok := app.getTwoFactor(w, r, user.ID)
+	if !ok {
+		return
+	}
+	if tf.Enabled {
+		app.conflictError(w, r, errors.New("two factor authentication is already enabled"))
+		return
+	}
+	step, ok := totp.Validate(tf.Secret, payload.Code, time.Now())
+	if !ok {
+		app.badRequestReponse(w, r, errors.New("the code is invalid"))
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.Storage.TwoFactor.Confirm(r.Context(), user.ID, step, hashes); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.conflictError(w, r, errors.New("two factor authentication is already enabled"))
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, RecoveryCodes{RecoveryCodes: codes}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DisableTwoFactor godoc
+//
+//	@Summary		Disables two factor authentication
+//	@Description	Removes the TOTP secret and the recovery codes of the current user. An enabled secret needs a current code or a recovery code
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body	TwoFactorCodePayLoad	true	"TOTP or recovery code"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		404	{object}	error	"Not enrolled"
+//	@Failure		429	{object}	error	"Too many failed codes"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/2fa [delete]
+func (app *Application) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorCodePayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	user := getUserFromCtx(r)
+	tf, ok := app.getTwoFactor(w, r, user.ID)
+	if !ok {
+		return
+	}
+	if tf.Enabled {
+		valid, err := app.checkSecondFactor(r.Context(), tf, payload)
+		if err != nil {
+			switch {
+			case errors.Is(err, storage.ErrLocked):
+				app.tooManyRequestsResponse(w, r, err)
+				return
+			default:
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+		if !valid {
+			app.badRequestReponse(w, r, errors.New("the code is invalid"))
+			return
+		}
+	}
+
+	if err := app.Storage.TwoFactor.Disable(r.Context(), user.ID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createTwoFactorTokenHandler godoc
+//
+//	@Summary		Completes a two factor login
+//	@Description	Exchanges the challenge token of a login and a TOTP or recovery code for the access and refresh tokens.
+//	@Description	A challenge is used up by the first code sent, a wrong code starts the login over, and too many wrong
+//	@Description	codes lock the second factor for a while
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		TwoFactorLoginPayLoad	true	"Challenge token and code"
+//	@Success		201		{object}	TokenResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		429		{object}	error	"Too many failed codes"
+//	@Failure		500		{object}	error
+//	@Router			/authentication/token/2fa [post]
+func (app *Application) createTwoFactorTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload TwoFactorLoginPayLoad
+	if err := readJSON(r, &payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestReponse(w, r, err)
+		return
+	}
+
+	token, err := app.Auth.ValidateTokenFor(payload.ChallengeToken, app.challengeAudience())
+	if err != nil {
+		app.unAuthError(w, r, err)
+		return
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if scope, _ := claims["scope"].(string); scope != challengeScope {
+		app.unAuthError(w, r, errors.New("token is not a two factor challenge"))
+		return
+	}
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unAuthError(w, r, err)
+		return
+	}
+	challengeID, _ := claims["jti"].(string)
+	if challengeID == "" {
+		app.unAuthError(w, r, errors.New("the challenge has no ID"))
+		return
+	}
+
+	tf, err := app.Storage.TwoFactor.GetByUserID(r.Context(), userID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if tf == nil || !tf.Enabled {
+		app.unAuthError(w, r, errors.New("two factor authentication is not enabled"))
+		return
+	}
+	// The challenge is claimed before the code is checked, so a replayed challenge spends no TOTP step or
+	// recovery code.
+	if err := app.Storage.TwoFactor.UseChallenge(r.Context(), hashToken(challengeID), userID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.unAuthError(w, r, errors.New("the challenge was already used"))
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	valid, err := app.checkSecondFactor(r.Context(), tf, payload.TwoFactorCodePayLoad)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrLocked):
+			app.tooManyRequestsResponse(w, r, err)
+			return
+		default:
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if !valid {
+		app.unAuthError(w, r, errors.New("the code is invalid"))
+		return
+	}
+
+	device, _ := claims["device"].(string)
+	session := &storage.Session{
+		UserID:      userID,
+		DeviceLabel: device,
+		UserAgent:   userAgent(r),
+		IP:          clientIP(r),
+	}
+	tokens, err := app.startSession(r.Context(), session)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// twoFactorChallenge signs the token that lets the user finish the login with a second factor. Its jti is stored
+// so that the challenge completes a single login.
+func (app *Application) twoFactorChallenge(ctx context.Context, user *storage.User, deviceLabel string) (*TwoFactorChallenge, error) {
+	now := time.Now()
+	exp := app.Config.TwoFactorConfig.ChallengeExp
+	challengeID, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := app.Storage.TwoFactor.CreateChallenge(ctx, hashToken(challengeID), user.ID, exp); err != nil {
+		return nil, err
+	}
+	claims := jwt.MapClaims{
+		"jti":    challengeID,
+		"sub":    user.ID,
+		"scope":  challengeScope,
+		"device": deviceLabel,
+		"exp":    now.Add(exp).Unix(),
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"iss":    app.Config.AuthConfig.Token.Iss,
+		"aud":    app.challengeAudience(),
+	}
+
+	token, err := app.Auth.GenerateToken(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorChallenge{TwoFactorRequired: true, ChallengeToken: token, ExpiresIn: int64(exp.Seconds())}, nil
+}
+
+// challengeAudience is the audience of the two factor challenges, the access tokens are issued for the issuer.
+func (app *Application) challengeAudience() string {
+	return app.Config.AuthConfig.Token.Iss + "/" + challengeScope
+}
+
+// getTwoFactor loads the two factor enrollment of the user and writes the error response on failure.
+func (app *Application) getTwoFactor(w http.ResponseWriter, r *http.Request, userID int64) (*storage.TwoFactor, bool) {
+	tf, err := app.Storage.TwoFactor.GetByUserID(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundReponse(w, r, errors.New("two factor authentication is not enrolled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+	return tf, true
+}
+
+// checkSecondFactor accepts a TOTP code whose step was not used yet or an unspent recovery code, using it up. Every
+// check counts as an attempt, see TwoFactorStorage.CountAttempt, a locked second factor fails with ErrLocked.
+func (app *Application) checkSecondFactor(ctx context.Context, tf *storage.TwoFactor, payload TwoFactorCodePayLoad) (bool, error) {
+	cfg := app.Config.TwoFactorConfig
+	if err := app.Storage.TwoFactor.CountAttempt(ctx, tf.UserID, cfg.MaxAttempts, cfg.Lockout); err != nil {
+		return false, err
+	}
+	valid, err := app.verifySecondFactor(ctx, tf, payload)
+	if err != nil || !valid {
+		return false, err
+	}
+	return true, app.Storage.TwoFactor.ResetAttempts(ctx, tf.UserID)
+}
+
+func (app *Application) verifySecondFactor(ctx context.Context, tf *storage.TwoFactor, payload TwoFactorCodePayLoad) (bool, error) {
+	if payload.Code != "" {
+		step, ok := totp.Validate(tf.Secret, payload.Code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		err := app.Storage.TwoFactor.UseStep(ctx, tf.UserID, step)
+		switch {
+		case errors.Is(err, storage.ErrCodeUsed):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+		return true, nil
+	}
+
+	err := app.Storage.TwoFactor.UseRecoveryCode(ctx, tf.UserID, hashToken(normalizeRecoveryCode(payload.RecoveryCode)))
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// newRecoveryCodes returns the recovery codes formatted for the user and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	b := make([]byte, recoveryCodeLength*5/8)
+	for i := range codes {
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(b))
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+		hashes[i] = hashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops the formatting of a recovery code as typed by the user.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dunkykorZhik/social/internal/auth"
+	"github.com/dunkykorZhik/social/internal/storage"
+	"github.com/dunkykorZhik/social/internal/totp"
+)
+
+func TestTwoFactor(t *testing.T) {
+	app := newTestApplication(t)
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	t.Run("should enroll with a provisioning URI", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/2fa", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusCreated)
+		if !strings.Contains(rr.Body.String(), `"provisioning_uri":"otpauth://totp/test:`) {
+			t.Errorf("Expected a provisioning URI, but got %s", rr.Body.String())
+		}
+	})
+	t.Run("should confirm with a code and return recovery codes", func(t *testing.T) {
+		code, err := totp.Code(storage.MockTOTPSecret, totp.Step(time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/2fa/confirm", strings.NewReader(`{"code":"`+code+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusOK)
+
+		var body struct {
+			Data RecoveryCodes `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Data.RecoveryCodes) != recoveryCodeCount {
+			t.Errorf("Expected %d recovery codes, but got %v", recoveryCodeCount, body.Data.RecoveryCodes)
+		}
+	})
+	t.Run("should refuse a wrong code", func(t *testing.T) {
+		for _, body := range []string{`{"code":"12ab56"}`, `{}`} {
+			req, err := http.NewRequest(http.MethodPost, "/v1/users/me/2fa/confirm", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponse(t, rr.Code, http.StatusBadRequest)
+		}
+	})
+	t.Run("should cancel a pending enrollment", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me/2fa", strings.NewReader(`{"recovery_code":"abcde-fghij"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusNoContent)
+	})
+	t.Run("should not take an access token as a challenge", func(t *testing.T) {
+		body := `{"challenge_token":"` + testToken + `","code":"123456"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token/2fa", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusUnauthorized)
+	})
+	t.Run("should require a code for the second step", func(t *testing.T) {
+		body := `{"challenge_token":"` + testToken + `"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token/2fa", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, mux)
+		checkResponse(t, rr.Code, http.StatusBadRequest)
+	})
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, code := range codes {
+		typed := " " + strings.ToUpper(code) + " "
+		if hashToken(normalizeRecoveryCode(typed)) != hashes[i] {
+			t.Errorf("Expected %q to match its hash", typed)
+		}
+	}
+}
+
+// enabledTwoFactorStorage is the mock two factor storage of a user who confirmed the enrollment.
+type enabledTwoFactorStorage struct {
+	*storage.TwoFactorMockStorage
+}
+
+func (s *enabledTwoFactorStorage) GetByUserID(ctx context.Context, userID int64) (*storage.TwoFactor, error) {
+
+	return &storage.TwoFactor{UserID: userID, Secret: storage.MockTOTPSecret, Enabled: true}, nil
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	app := newTestApplication(t)
+	// the challenges carry their own claims, which the mock authenticator does not sign
+	app.Config.AuthConfig.Token.Iss = "test"
+	app.Auth = auth.NewAuthenticator("secret", "test", "test")
+	app.Storage.TwoFactor = &enabledTwoFactorStorage{app.Storage.TwoFactor.(*storage.TwoFactorMockStorage)}
+
+	mux := app.Mount()
+
+	code, err := totp.Code(storage.MockTOTPSecret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongCode := fmt.Sprintf("%06d", (mustAtoi(t, code)+1)%1_000_000)
+	redeem := func(t *testing.T, challenge, payload string) int {
+		t.Helper()
+		body := `{"challenge_token":"` + challenge + `",` + payload + `}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token/2fa", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return executeRequest(req, mux).Code
+	}
+	newChallenge := func(t *testing.T) string {
+		t.Helper()
+		challenge, err := app.twoFactorChallenge(context.Background(), &storage.User{ID: 1}, "laptop")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return challenge.ChallengeToken
+	}
+
+	t.Run("should redeem a challenge once", func(t *testing.T) {
+		challenge := newChallenge(t)
+		checkResponse(t, redeem(t, challenge, `"code":"`+code+`"`), http.StatusCreated)
+		// replays are refused before the code is checked, so they do not count towards the lockout
+		for range app.Config.TwoFactorConfig.MaxAttempts + 1 {
+			checkResponse(t, redeem(t, challenge, `"code":"`+wrongCode+`"`), http.StatusUnauthorized)
+		}
+		checkResponse(t, redeem(t, newChallenge(t), `"recovery_code":"abcde-fghij"`), http.StatusCreated)
+	})
+	t.Run("should not take a challenge as an access token", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+newChallenge(t))
+		checkResponse(t, executeRequest(req, mux).Code, http.StatusUnauthorized)
+
+		if _, err := app.Auth.ValidateToken(newChallenge(t)); err == nil {
+			t.Error("Expected the challenge to fail validation as an access token")
+		}
+	})
+	t.Run("should lock the second factor after too many wrong codes", func(t *testing.T) {
+		for range app.Config.TwoFactorConfig.MaxAttempts {
+			checkResponse(t, redeem(t, newChallenge(t), `"code":"`+wrongCode+`"`), http.StatusUnauthorized)
+		}
+		checkResponse(t, redeem(t, newChallenge(t), `"recovery_code":"abcde-fghij"`), http.StatusTooManyRequests)
+	})
+}
+
+func TestTwoFactorAlreadyEnabled(t *testing.T) {
+	app := newTestApplication(t)
+	app.Storage.TwoFactor = &enabledTwoFactorStorage{app.Storage.TwoFactor.(*storage.TwoFactorMockStorage)}
+
+	mux := app.Mount()
+
+	testToken, _ := app.Auth.GenerateToken(nil)
+	req, err := http.NewRequest(http.MethodPost, "/v1/users/me/2fa/confirm", strings.NewReader(`{"code":"123456"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponse(t, rr.Code, http.StatusConflict)
+	if !strings.Contains(rr.Body.String(), "already enabled") {
+		t.Errorf("Expected the conflict to be explained, but got %s", rr.Body.String())
+	}
+}
+
+func mustAtoi(t *testing.T, s string) int {
+	t.Helper()
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
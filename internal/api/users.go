@@ -16,7 +16,8 @@ const userCtx userKey = "user"
 // GetUserHandler godoc
 //
 //	@Summary		Fetches the User
-//	@Description	Fetches the User info using ID
+//	@Description	Fetches the User info using ID with its follower, following and post counts and, for another
+//	@Description	user, how the two follow each other
 //	@Tags			user
 //	@Accept			json
 //	@Produce		json
@@ -48,6 +49,12 @@ func (app *Application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	}
 
+	user, err = app.withUserStats(r.Context(), user, getUserFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -153,6 +160,27 @@ func getUserFromCtx(r *http.Request) *storage.User {
 //	@Security		ApiKeyAuth
 //	@Router			/users/feed [get]
 func (app *Application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
+	pq, ok := app.readPaginateQuery(w, r)
+	if !ok {
+		return
+	}
+	user := getUserFromCtx(r)
+
+	ctx := r.Context()
+	feed, err := app.Storage.Users.GetUserFeed(ctx, user.ID, pq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.prepareFeed(feed, user.ID)
+	if err = app.jsonResponse(w, http.StatusOK, feed); err != nil {
+		app.internalServerError(w, r, err)
+
+	}
+}
+
+// readPaginateQuery parses and validates the feed style query parameters and writes the error response on failure.
+func (app *Application) readPaginateQuery(w http.ResponseWriter, r *http.Request) (storage.PaginateQuery, bool) {
 	pq := storage.PaginateQuery{
 		Limit:  20,
 		Offset: 0,
@@ -164,24 +192,28 @@ func (app *Application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	pq, err := pq.Parse(r)
 	if err != nil {
 		app.badRequestReponse(w, r, err)
-		return
+		return pq, false
 	}
 
 	err = Validate.Struct(pq)
 	if err != nil {
 		app.badRequestReponse(w, r, err)
+		return pq, false
 	}
-	user := getUserFromCtx(r)
 
-	ctx := r.Context()
-	feed, err := app.Storage.Users.GetUserFeed(ctx, user.ID, pq)
+	pq.Tags, err = normalizeTags(pq.Tags)
 	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+		app.badRequestReponse(w, r, err)
+		return pq, false
 	}
-	if err = app.jsonResponse(w, http.StatusOK, feed); err != nil {
-		app.internalServerError(w, r, err)
+	return pq, true
+}
 
+// prepareFeed fills in the attachment URLs signed for the viewer and the rendered content of the feed items.
+func (app *Application) prepareFeed(feed []storage.PostForFeed, viewerID int64) {
+	for i := range feed {
+		app.withAttachmentURLs(feed[i].Post.Attachments, viewerID)
+		renderPost(&feed[i].Post)
 	}
 }
 
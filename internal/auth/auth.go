@@ -5,4 +5,23 @@ import "github.com/golang-jwt/jwt/v5"
 type Authenticator interface {
 	GenerateToken(jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
+	// ValidateTokenFor validates a token the service issued for another audience than its access tokens.
+	ValidateTokenFor(token, aud string) (*jwt.Token, error)
+	JWKS() JWKSet
+}
+
+// JWK is the public part of a signing key as described in RFC 7517, RSA keys set N and E, Ed25519 keys Crv and X.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
 }
@@ -27,6 +27,10 @@ func (j JwtAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 }
 
 func (j JwtAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return j.ValidateTokenFor(token, j.aud)
+}
+
+func (j JwtAuthenticator) ValidateTokenFor(token, aud string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
@@ -35,8 +39,13 @@ func (j JwtAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		return []byte(j.secret), nil
 	},
 		jwt.WithExpirationRequired(),
-		jwt.WithAudience(j.aud),
+		jwt.WithAudience(aud),
 		jwt.WithIssuer(j.iss),
 		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
 	)
 }
+
+// JWKS is empty, a shared secret cannot be published.
+func (j JwtAuthenticator) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{}}
+}
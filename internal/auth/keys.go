@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrNoSigningKey = errors.New("no signing key is active")
+	ErrUnknownKey   = errors.New("token is signed with an unknown or retired key")
+)
+
+// Key is a signing key pair identified by its kid. It signs the tokens from ActiveFrom until the next key becomes
+// active, and verifies them until the grace period after that has passed.
+type Key struct {
+	ID         string
+	ActiveFrom time.Time
+	method     jwt.SigningMethod
+	private    crypto.Signer
+}
+
+// ParseKey reads a PEM encoded RSA or Ed25519 private key. RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func ParseKey(id string, data []byte, activeFrom time.Time) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM data", id)
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+	return NewKey(id, private, activeFrom)
+}
+
+// NewKey wraps an *rsa.PrivateKey or ed25519.PrivateKey.
+func NewKey(id string, private any, activeFrom time.Time) (*Key, error) {
+	if id == "" {
+		return nil, errors.New("key id is empty")
+	}
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("key %s: RSA keys must have at least 2048 bits", id)
+		}
+		return &Key{ID: id, ActiveFrom: activeFrom, method: jwt.SigningMethodRS256, private: k}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: id, ActiveFrom: activeFrom, method: jwt.SigningMethodEdDSA, private: k}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", id, private)
+	}
+}
+
+// LoadKeys reads the keys listed in spec, a comma separated list of kid=path entries. An entry can schedule its key
+// with kid=path@activeFrom, the time in RFC 3339, a key without one is active right away. Only one key may be
+// unscheduled: a second one would count as active since forever and retire the first at once, logging everyone out.
+func LoadKeys(spec string) ([]*Key, error) {
+	var keys []*Key
+	unscheduled := ""
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("key entry %q is not kid=path", entry)
+		}
+		var activeFrom time.Time
+		path, from, scheduled := strings.Cut(path, "@")
+		switch {
+		case scheduled:
+			t, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", id, err)
+			}
+			activeFrom = t
+		case unscheduled != "":
+			return nil, fmt.Errorf("keys %s and %s have no activation time, schedule the new key with @activeFrom", unscheduled, id)
+		default:
+			unscheduled = id
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		key, err := ParseKey(id, data, activeFrom)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// KeyAuthenticator signs tokens with the newest active key and names it in the kid header, so the keys can be
+// rotated by scheduling a new one. Tokens of the previous key keep validating for the grace period, which should
+// be at least the lifetime of a token.
+type KeyAuthenticator struct {
+	keys  []*Key
+	grace time.Duration
+	aud   string
+	iss   string
+	now   func() time.Time
+}
+
+func NewKeyAuthenticator(keys []*Key, grace time.Duration, aud, iss string) (*KeyAuthenticator, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys given")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("key %s is given twice", key.ID)
+		}
+		seen[key.ID] = true
+	}
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ActiveFrom.Before(sorted[j].ActiveFrom) })
+
+	return &KeyAuthenticator{keys: sorted, grace: grace, aud: aud, iss: iss, now: time.Now}, nil
+}
+
+func (k *KeyAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
+	key := k.signingKey(k.now())
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.private)
+}
+
+func (k *KeyAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
+	return k.ValidateTokenFor(token, k.aud)
+}
+
+func (k *KeyAuthenticator) ValidateTokenFor(token, aud string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		for _, key := range k.published(k.now()) {
+			if key.ID != kid {
+				continue
+			}
+			if t.Method.Alg() != key.method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+			return key.private.Public(), nil
+		}
+		return nil, ErrUnknownKey
+	},
+		jwt.WithExpirationRequired(),
+		jwt.WithAudience(aud),
+		jwt.WithIssuer(k.iss),
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+	)
+}
+
+// JWKS publishes the public halves of the keys that sign or verify tokens, scheduled keys included so verifiers
+// know them before the first token is signed with them.
+func (k *KeyAuthenticator) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.published(k.now()) {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.method.Alg()}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// signingKey is the key that became active last.
+func (k *KeyAuthenticator) signingKey(now time.Time) *Key {
+	var signing *Key
+	for _, key := range k.keys {
+		if key.ActiveFrom.After(now) {
+			break
+		}
+		signing = key
+	}
+	return signing
+}
+
+// published leaves out the keys whose successor has been active for longer than the grace period.
+func (k *KeyAuthenticator) published(now time.Time) []*Key {
+	var keys []*Key
+	for i, key := range k.keys {
+		if i+1 < len(k.keys) && now.After(k.keys[i+1].ActiveFrom.Add(k.grace)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
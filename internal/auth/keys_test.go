@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testClaimsAt(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": 1,
+		"aud": "test",
+		"iss": "test",
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestKeyAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	old, err := NewKey("old", rsaKey, start.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := NewKey("next", edKey, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewKeyAuthenticator([]*Key{next, old}, 30*time.Minute, "test", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := start
+	a.now = func() time.Time { return now }
+
+	t.Run("should sign with the active key", func(t *testing.T) {
+		token, err := a.GenerateToken(testClaimsAt(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := a.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header["kid"] != "old" || parsed.Method.Alg() != "RS256" {
+			t.Errorf("Expected an RS256 token of key old, but got %v", parsed.Header)
+		}
+		if got := len(a.JWKS().Keys); got != 2 {
+			t.Errorf("Expected the scheduled key to be published, but got %d keys", got)
+		}
+	})
+
+	oldToken, err := a.GenerateToken(testClaimsAt(now.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("should rotate to the scheduled key", func(t *testing.T) {
+		now = start.Add(time.Hour + time.Minute)
+		token, err := a.GenerateToken(testClaimsAt(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := a.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header["kid"] != "next" || parsed.Method.Alg() != "EdDSA" {
+			t.Errorf("Expected an EdDSA token of key next, but got %v", parsed.Header)
+		}
+		if _, err := a.ValidateToken(oldToken); err != nil {
+			t.Errorf("Expected the old key to verify during the grace period, but got %v", err)
+		}
+	})
+	t.Run("should retire the old key after the grace period", func(t *testing.T) {
+		now = start.Add(2 * time.Hour)
+		if _, err := a.ValidateToken(oldToken); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, but got %v", err)
+		}
+		set := a.JWKS()
+		if len(set.Keys) != 1 || set.Keys[0].Kid != "next" || set.Keys[0].Kty != "OKP" {
+			t.Errorf("Expected only key next to be published, but got %+v", set.Keys)
+		}
+	})
+	t.Run("should reject tokens signed with the shared secret", func(t *testing.T) {
+		token, err := NewAuthenticator("secret", "test", "test").GenerateToken(testClaimsAt(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.ValidateToken(token); err == nil {
+			t.Error("Expected an HS256 token to be rejected")
+		}
+	})
+}
+
+func TestLoadKeys(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "ed.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeys("a=" + path + ", b=" + path + "@2030-01-02T15:04:05Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "a" || !keys[0].ActiveFrom.IsZero() || keys[1].ActiveFrom.Year() != 2030 {
+		t.Errorf("Unexpected keys %+v", keys)
+	}
+	if _, err := LoadKeys("missing-path"); err == nil {
+		t.Error("Expected an entry without a path to fail")
+	}
+	if _, err := LoadKeys("a=" + path + ", b=" + path); err == nil {
+		t.Error("Expected a second unscheduled key to fail")
+	}
+}
@@ -19,6 +19,7 @@ var testClaims = jwt.MapClaims{
 	"aud": "test-aud",
 	"iss": "test-aud",
 	"sub": int64(1),
+	"sid": int64(1),
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
@@ -34,3 +35,11 @@ func (j MockAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		return []byte(secret), nil
 	})
 }
+
+func (j MockAuthenticator) ValidateTokenFor(token, aud string) (*jwt.Token, error) {
+	return j.ValidateToken(token)
+}
+
+func (j MockAuthenticator) JWKS() JWKSet {
+	return JWKSet{Keys: []JWK{}}
+}
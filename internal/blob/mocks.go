@@ -0,0 +1,60 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+type MockStore struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func NewMockStore() *MockStore {
+	return &MockStore{blobs: make(map[string][]byte)}
+}
+
+func (m *MockStore) Put(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blobs[key] = data
+	return nil
+}
+
+func (m *MockStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MockStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+func (m *MockStore) URL(key string) string {
+	return "/v1/media/" + key
+}
+
+// Keys lists the stored keys, in no particular order.
+func (m *MockStore) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.blobs))
+	for key := range m.blobs {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,64 @@
+package diff
+
+import "regexp"
+
+const (
+	Equal  = "equal"
+	Insert = "insert"
+	Delete = "delete"
+)
+
+type Op struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+var tokenRe = regexp.MustCompile(`\s+|\S+`)
+
+// Words returns the word level edits turning from into to, whitespace is kept as its own token
+// so joining the texts of the equal and insert ops gives back to.
+func Words(from, to string) []Op {
+	a := tokenRe.FindAllString(from, -1)
+	b := tokenRe.FindAllString(to, -1)
+
+	// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := []Op{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = appendOp(ops, Equal, a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			ops = appendOp(ops, Insert, b[j])
+			j++
+		default:
+			ops = appendOp(ops, Delete, a[i])
+			i++
+		}
+	}
+	return ops
+}
+
+func appendOp(ops []Op, kind, text string) []Op {
+	if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+		ops[n-1].Text += text
+		return ops
+	}
+	return append(ops, Op{Kind: kind, Text: text})
+}
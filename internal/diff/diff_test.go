@@ -0,0 +1,24 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	got := Words("the quick fox", "the slow fox jumps")
+	want := []Op{
+		{Kind: Equal, Text: "the "},
+		{Kind: Insert, Text: "slow"},
+		{Kind: Delete, Text: "quick"},
+		{Kind: Equal, Text: " fox"},
+		{Kind: Insert, Text: " jumps"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, but got %v", want, got)
+	}
+
+	if got := Words("same", "same"); !reflect.DeepEqual(got, []Op{{Kind: Equal, Text: "same"}}) {
+		t.Errorf("Expected a single equal op, but got %v", got)
+	}
+}
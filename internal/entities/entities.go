@@ -0,0 +1,124 @@
+// Package entities finds the structured parts of post and comment text, such as @mentions, #hashtags and links.
+//
+// Offsets are counted in characters (runes) from the start of the text, End is exclusive.
+package entities
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxTagLength is the longest tag in characters, longer hashtags are not recognized.
+const MaxTagLength = 50
+
+// Entity is a match in the text, Text holds the match without its leading marker.
+type Entity struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// mentionRe matches @username when the @ does not follow a word character, so e-mail addresses are skipped.
+var mentionRe = regexp.MustCompile(`(?:^|[^\w@])(@([A-Za-z0-9_]{1,100}))\b`)
+
+// hashtagRe matches #tag when the # does not follow a word character or an ampersand, which skips URL fragments
+// and HTML entities.
+var hashtagRe = regexp.MustCompile(`(?:^|[^\w&#])(#([\p{L}\p{N}_]+))`)
+
+// urlRe matches a http or https link up to the next whitespace, quote or angle bracket, the trailing punctuation is
+// trimmed by URLs.
+var urlRe = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// Mentions returns the @mentions in the text in order of appearance.
+func Mentions(text string) []Entity {
+	return find(text, mentionRe)
+}
+
+// Hashtags returns the #hashtags in the text in order of appearance, their Text is normalized by NormalizeTag.
+func Hashtags(text string) []Entity {
+	hashtags := []Entity{}
+	for _, entity := range find(text, hashtagRe) {
+		if tag, ok := NormalizeTag(entity.Text); ok {
+			entity.Text = tag
+			hashtags = append(hashtags, entity)
+		}
+	}
+	return hashtags
+}
+
+// URLs returns the http and https links in the text in order of appearance, Text holds the whole link. Punctuation
+// ending a sentence and closing brackets without an opening one inside the link are not part of it.
+func URLs(text string) []Entity {
+	urls := []Entity{}
+	for _, m := range urlRe.FindAllStringIndex(text, -1) {
+		link := trimLink(text[m[0]:m[1]])
+		if link == "" {
+			continue
+		}
+		start := utf8.RuneCountInString(text[:m[0]])
+		urls = append(urls, Entity{
+			Text:  link,
+			Start: start,
+			End:   start + utf8.RuneCountInString(link),
+		})
+	}
+	return urls
+}
+
+// trimLink drops the trailing punctuation and unbalanced closing brackets, a link left without a host is dropped.
+func trimLink(link string) string {
+	for link != "" {
+		last := link[len(link)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'*_", last) >= 0:
+		case last == ')' && strings.Count(link, "(") < strings.Count(link, ")"):
+		case last == ']' && strings.Count(link, "[") < strings.Count(link, "]"):
+		default:
+			if strings.HasSuffix(link, "//") {
+				return ""
+			}
+			return link
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
+
+// NormalizeTag lower-cases the tag and drops a leading #. Tags must be made of letters, digits and
+// underscores, contain at least one letter and be at most MaxTagLength characters long.
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", false
+	}
+	letters := 0
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			letters++
+		case unicode.IsDigit(r) || r == '_':
+		default:
+			return "", false
+		}
+	}
+	if letters == 0 {
+		return "", false
+	}
+	return tag, true
+}
+
+// find collects the matches of re, its first group spans the entity including the marker and the second one its text.
+func find(text string, re *regexp.Regexp) []Entity {
+	found := []Entity{}
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		start := utf8.RuneCountInString(text[:m[2]])
+		found = append(found, Entity{
+			Text:  text[m[4]:m[5]],
+			Start: start,
+			End:   start + utf8.RuneCountInString(text[m[2]:m[3]]),
+		})
+	}
+	return found
+}
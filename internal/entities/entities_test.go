@@ -0,0 +1,94 @@
+package entities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{"none", "hello world", []Entity{}},
+		{"start", "@alice hi", []Entity{{"alice", 0, 6}}},
+		{"several", "hi @alice and @bob_2!", []Entity{{"alice", 3, 9}, {"bob_2", 14, 20}}},
+		{"rune offsets", "héllo @bob", []Entity{{"bob", 6, 10}}},
+		{"email", "mail me at bob@example.com", []Entity{}},
+		{"double at", "@@alice", []Entity{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{"none", "hello world", []Entity{}},
+		{"normalized", "#Go is fun #golang_2024", []Entity{{"go", 0, 3}, {"golang_2024", 11, 23}}},
+		{"unicode", "поехали #Космос", []Entity{{"космос", 8, 15}}},
+		{"numbers only", "issue #42", []Entity{}},
+		{"url fragment and entity", "see https://a.com/x#top &#39;", []Entity{}},
+		{"too long", "#" + strings.Repeat("a", MaxTagLength+1), []Entity{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Hashtags(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		ok   bool
+	}{
+		{"#GoLang", "golang", true},
+		{" news ", "news", true},
+		{"two words", "", false},
+		{"2024", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeTag(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeTag(%q): expected %q %v, but got %q %v", tt.tag, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{"none", "hello world", []Entity{}},
+		{"sentence", "see https://example.com/a?b=1.", []Entity{{"https://example.com/a?b=1", 4, 29}}},
+		{"markdown link", "[docs](http://example.com/x_(y))", []Entity{{"http://example.com/x_(y)", 7, 31}}},
+		{"parenthesized", "(https://example.com)", []Entity{{"https://example.com", 1, 20}}},
+		{"several", "https://a.io and http://b.io", []Entity{{"https://a.io", 0, 12}, {"http://b.io", 17, 28}}},
+		{"no host", "https://", []Entity{}},
+		{"other scheme", "ftp://example.com", []Entity{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := URLs(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, but got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+// Package markdown renders the Markdown subset used by posts and comments to HTML.
+//
+// Every piece of user text is escaped and tags are only ever produced from Markdown syntax, so raw HTML in
+// the source shows up as text and the output is safe to embed without a separate sanitizer. Supported are
+// paragraphs, ATX headings, block quotes, flat ordered and unordered lists, fenced code blocks, horizontal
+// rules, emphasis, strong emphasis, code spans, links and bare http(s) URLs.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRe     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	unorderedRe   = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	orderedRe     = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	ruleRe        = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	fenceRe       = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+	blockquoteRe  = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	bareURLRe     = regexp.MustCompile(`^https?://[^\s<>"]+`)
+	trailingPunct = ".,:;!?'\")"
+)
+
+// Render converts the Markdown source to HTML.
+func Render(source string) string {
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+	b := &strings.Builder{}
+	renderBlocks(b, lines)
+	return b.String()
+}
+
+// RenderPlain renders text that is not Markdown, keeping its line breaks.
+func RenderPlain(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n") + "</p>\n"
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRe.MatchString(line):
+			fence := fenceRe.FindStringSubmatch(line)[1]
+			b.WriteString("<pre><code>")
+			i++
+			for ; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					i++
+					break
+				}
+				b.WriteString(html.EscapeString(lines[i]))
+				b.WriteString("\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+			i++
+
+		case ruleRe.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case blockquoteRe.MatchString(line):
+			quoted := []string{}
+			for ; i < len(lines) && blockquoteRe.MatchString(lines[i]); i++ {
+				quoted = append(quoted, blockquoteRe.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case unorderedRe.MatchString(line):
+			i = renderList(b, lines, i, unorderedRe, "ul")
+
+		case orderedRe.MatchString(line):
+			i = renderList(b, lines, i, orderedRe, "ol")
+
+		default:
+			paragraph := []string{}
+			for ; i < len(lines) && startsParagraphLine(lines[i]); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+			text := renderInline(strings.Join(paragraph, "\n"))
+			b.WriteString("<p>" + strings.ReplaceAll(text, "\n", "<br>\n") + "</p>\n")
+		}
+	}
+}
+
+// startsParagraphLine tells whether the line continues a paragraph instead of starting another block.
+func startsParagraphLine(line string) bool {
+	return strings.TrimSpace(line) != "" && !fenceRe.MatchString(line) && !headingRe.MatchString(line) &&
+		!ruleRe.MatchString(line) && !blockquoteRe.MatchString(line) && !unorderedRe.MatchString(line) &&
+		!orderedRe.MatchString(line)
+}
+
+func renderList(b *strings.Builder, lines []string, i int, itemRe *regexp.Regexp, tag string) int {
+	b.WriteString("<" + tag + ">\n")
+	for ; i < len(lines) && itemRe.MatchString(lines[i]); i++ {
+		b.WriteString("<li>" + renderInline(itemRe.FindStringSubmatch(lines[i])[1]) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return i
+}
+
+func renderInline(s string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\`*_[]()#+-.!>~", rune(rest[1])):
+			b.WriteString(html.EscapeString(rest[1:2]))
+			i += 2
+			continue
+
+		case rest[0] == '`':
+			ticks := len(rest) - len(strings.TrimLeft(rest, "`"))
+			if end := strings.Index(rest[ticks:], rest[:ticks]); end >= 0 {
+				code := strings.TrimSpace(rest[ticks : ticks+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += ticks + end + ticks
+				continue
+			}
+			b.WriteString(rest[:ticks])
+			i += ticks
+			continue
+
+		case rest[0] == '_' && i > 0 && isWordByte(s[i-1]):
+			// intraword underscores as in snake_case stay literal
+
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if n, ok := wrapDelimited(b, rest, rest[:2], "strong"); ok {
+				i += n
+				continue
+			}
+
+		case rest[0] == '*' || rest[0] == '_':
+			if n, ok := wrapDelimited(b, rest, rest[:1], "em"); ok {
+				i += n
+				continue
+			}
+
+		case rest[0] == '[':
+			if n, ok := renderLink(b, rest); ok {
+				i += n
+				continue
+			}
+
+		case rest[0] == 'h' && (i == 0 || !isWordByte(s[i-1])):
+			if m := bareURLRe.FindString(rest); m != "" {
+				m = strings.TrimRight(m, trailingPunct)
+				if href, ok := safeURL(m); ok {
+					b.WriteString(anchor(href, html.EscapeString(m)))
+					i += len(m)
+					continue
+				}
+			}
+		}
+
+		b.WriteString(html.EscapeString(rest[:1]))
+		i++
+	}
+	return b.String()
+}
+
+// wrapDelimited renders s[len(delim):closing] inside tag when the delimiter is closed on the same paragraph.
+func wrapDelimited(b *strings.Builder, s, delim, tag string) (int, bool) {
+	inner := s[len(delim):]
+	if inner == "" || inner[0] == ' ' {
+		return 0, false
+	}
+	end := strings.Index(inner, delim)
+	for end > 0 && inner[end-1] == '\\' {
+		next := strings.Index(inner[end+1:], delim)
+		if next < 0 {
+			return 0, false
+		}
+		end += 1 + next
+	}
+	if end <= 0 || inner[end-1] == ' ' {
+		return 0, false
+	}
+	b.WriteString("<" + tag + ">" + renderInline(inner[:end]) + "</" + tag + ">")
+	return len(delim) + end + len(delim), true
+}
+
+// renderLink renders [text](url) links, links with an unsafe URL are rendered as their text only.
+func renderLink(b *strings.Builder, s string) (int, bool) {
+	closeText := strings.Index(s, "](")
+	if closeText < 0 {
+		return 0, false
+	}
+	closeURL := closingParen(s[closeText+2:])
+	if closeURL < 0 {
+		return 0, false
+	}
+	text := s[1:closeText]
+	target := strings.TrimSpace(s[closeText+2 : closeText+2+closeURL])
+	n := closeText + 2 + closeURL + 1
+
+	if href, ok := safeURL(target); ok {
+		b.WriteString(anchor(href, renderInline(text)))
+	} else {
+		b.WriteString(renderInline(text))
+	}
+	return n, true
+}
+
+// closingParen finds the parenthesis closing a link target, balanced pairs inside the URL are kept.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func anchor(href, text string) string {
+	return `<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">` + text + `</a>`
+}
+
+// safeURL only lets absolute http, https and mailto URLs through.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,38 @@
+package markdown
+
+import "testing"
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"paragraph", "hello\nworld", "<p>hello<br>\nworld</p>\n"},
+		{"heading", "## Title ##", "<h2>Title</h2>\n"},
+		{"emphasis", "**bold** and *it* and snake_case_name", "<p><strong>bold</strong> and <em>it</em> and snake_case_name</p>\n"},
+		{"code", "use `<b>` tags\n```\n<script>\n```", "<p>use <code>&lt;b&gt;</code> tags</p>\n<pre><code>&lt;script&gt;\n</code></pre>\n"},
+		{"list", "- one\n- two\n\n1. first", "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>\n"},
+		{"quote", "> quoted *text*", "<blockquote>\n<p>quoted <em>text</em></p>\n</blockquote>\n"},
+		{"link", "[site](https://example.com/a?b=1&c=2)", `<p><a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">site</a></p>` + "\n"},
+		{"bare url", "see https://example.com.", `<p>see <a href="https://example.com" rel="nofollow noopener noreferrer">https://example.com</a>.</p>` + "\n"},
+		{"raw html", `<script>alert("x")</script>`, "<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</p>\n"},
+		{"javascript link", "[click](javascript:alert(1))", "<p>click</p>\n"},
+		{"parens in url", "[wiki](https://en.wikipedia.org/wiki/Go_(game))", `<p><a href="https://en.wikipedia.org/wiki/Go_(game)" rel="nofollow noopener noreferrer">wiki</a></p>` + "\n"},
+		{"attribute injection", `[x](https://a.com/"onmouseover="alert(1))`, `<p><a href="https://a.com/%22onmouseover=%22alert%281%29" rel="nofollow noopener noreferrer">x</a></p>` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.source); got != tt.want {
+				t.Errorf("Expected %q, but got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderPlain(t *testing.T) {
+	want := "<p>a &lt;b&gt;<br>\n*c*</p>\n"
+	if got := RenderPlain("a <b>\n*c*"); got != want {
+		t.Errorf("Expected %q, but got %q", want, got)
+	}
+}
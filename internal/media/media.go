@@ -0,0 +1,128 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Size names a rendition that fits in a MaxSize x MaxSize square.
+type Size struct {
+	Name    string
+	MaxSize int
+}
+
+type Variant struct {
+	Name        string
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+type Result struct {
+	// Data is the upload without its metadata, ready to be stored in place of the original.
+	Data     []byte
+	Width    int
+	Height   int
+	Variants []Variant
+}
+
+// Process strips the metadata of an uploaded JPEG, PNG, GIF or WebP and renders the requested sizes that are
+// smaller than the image, WebP sizes are rendered as PNG. Other content types are returned untouched. Images
+// with more than maxPixels pixels are rejected before being decoded.
+func Process(contentType string, data []byte, sizes []Size, maxPixels int) (*Result, error) {
+	orientation := 1
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		data, orientation, err = stripJPEG(data)
+	case "image/png":
+		data, err = stripPNG(data)
+	case "image/gif":
+		data, err = stripGIF(data)
+	case "image/webp":
+		data, err = stripWebP(data)
+	default:
+		return &Result{Data: data}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// w x h is the size the image is displayed at once the orientation is applied
+	w, h := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		w, h = h, w
+	}
+	result := &Result{Data: data, Width: w, Height: h}
+
+	if orientation > 1 {
+		// the orientation tag went away with the EXIF data, so bake it into the pixels
+		upright := orient(resize(img, cfg.Width, cfg.Height), orientation)
+		if result.Data, err = encode(contentType, upright, 92); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, size := range sizes {
+		if w <= size.MaxSize && h <= size.MaxSize {
+			continue
+		}
+		tw, th := fit(w, h, size.MaxSize)
+		sw, sh := tw, th
+		if orientation >= 5 {
+			sw, sh = th, tw
+		}
+		thumb := orient(resize(img, sw, sh), orientation)
+
+		variant := Variant{Name: size.Name, ContentType: variantType(contentType), Width: tw, Height: th}
+		if variant.Data, err = encode(contentType, thumb, 85); err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, variant)
+	}
+
+	return result, nil
+}
+
+// variantType keeps JPEG renditions as JPEG, everything else becomes PNG so transparency survives.
+func variantType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+func encode(contentType string, img image.Image, quality int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	if variantType(contentType) == "image/jpeg" {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
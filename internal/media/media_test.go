@@ -0,0 +1,175 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), 128, 255})
+		}
+	}
+	return img
+}
+
+// exifSegment is an APP1 segment holding a big endian IFD0 with only the orientation tag.
+func exifSegment(orientation byte) []byte {
+	payload := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00")
+	payload = append(payload, orientation, 0, 0, 0, 0, 0, 0)
+	length := len(payload) + 2
+	return append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+}
+
+func TestProcessJPEG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data = append(append(append([]byte{}, data[:2]...), exifSegment(6)...), data[2:]...)
+
+	result, err := Process("image/jpeg", data, []Size{{Name: "small", MaxSize: 10}, {Name: "large", MaxSize: 100}}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(result.Data, []byte("Exif")) {
+		t.Errorf("Expected the EXIF data to be stripped")
+	}
+	if result.Width != 20 || result.Height != 40 {
+		t.Errorf("Expected 20x40, but got %dx%d", result.Width, result.Height)
+	}
+	if len(result.Variants) != 1 {
+		t.Fatalf("Expected 1 variant, but got %d", len(result.Variants))
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(result.Variants[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 5 || b.Dy() != 10 {
+		t.Errorf("Expected a 5x10 thumbnail, but got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestProcessPNG(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, testImage(8, 8)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// tEXt chunk "Comment\0hi" with a dummy checksum, it is dropped before anything reads it
+	chunk := []byte("\x00\x00\x00\x0atEXtComment\x00hi\x00\x00\x00\x00")
+	data = append(append(append([]byte{}, data[:33]...), chunk...), data[33:]...)
+
+	result, err := Process("image/png", data, nil, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(result.Data, []byte("tEXt")) {
+		t.Errorf("Expected the text chunk to be stripped")
+	}
+	if _, err := png.Decode(bytes.NewReader(result.Data)); err != nil {
+		t.Errorf("Expected a valid png, but got %v", err)
+	}
+
+	if _, err := Process("image/png", data, nil, 10); err != ErrTooLarge {
+		t.Errorf("Expected %v, but got %v", ErrTooLarge, err)
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := gif.Encode(buf, testImage(8, 8), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	at := 13
+	if data[10]&0x80 != 0 {
+		at += 3 << (data[10]&0x07 + 1)
+	}
+	comment := []byte("\x21\xfe\x05hello\x00")
+	xmp := []byte("\x21\xff\x0bXMP DataXMP\x03abc\x00")
+	data = append(append(append(append([]byte{}, data[:at]...), comment...), xmp...), data[at:]...)
+
+	result, err := Process("image/gif", data, []Size{{Name: "small", MaxSize: 4}}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(result.Data, []byte("hello")) || bytes.Contains(result.Data, []byte("XMP")) {
+		t.Errorf("Expected the comment and XMP extensions to be stripped")
+	}
+	if _, err := gif.Decode(bytes.NewReader(result.Data)); err != nil {
+		t.Errorf("Expected a valid gif, but got %v", err)
+	}
+	if result.Width != 8 || len(result.Variants) != 1 {
+		t.Errorf("Expected an 8 pixel wide gif with 1 variant, but got %d and %d", result.Width, len(result.Variants))
+	}
+}
+
+// webpChunk encodes a RIFF chunk, padded to an even length.
+func webpChunk(kind string, payload []byte) []byte {
+	chunk := binary.LittleEndian.AppendUint32([]byte(kind), uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestProcessWebP(t *testing.T) {
+	simple, err := os.ReadFile("testdata/gopher.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the 75x100 image of the simple file in an extended one, with the EXIF and XMP flags set
+	vp8x := []byte{0x0C, 0, 0, 0, 74, 0, 0, 99, 0, 0}
+	body := []byte("WEBP")
+	body = append(body, webpChunk("VP8X", vp8x)...)
+	body = append(body, simple[12:]...)
+	body = append(body, webpChunk("EXIF", append([]byte("MM\x00\x2a"), "secret"...))...)
+	body = append(body, webpChunk("XMP ", []byte("<x:xmpmeta>gps</x:xmpmeta>"))...)
+	data := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body)))
+	data = append(data, body...)
+
+	result, err := Process("image/webp", data, []Size{{Name: "small", MaxSize: 50}}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"EXIF", "secret", "XMP ", "xmpmeta"} {
+		if bytes.Contains(result.Data, []byte(s)) {
+			t.Errorf("Expected %q to be stripped", s)
+		}
+	}
+	if !bytes.Contains(result.Data, []byte("VP8L")) {
+		t.Errorf("Expected the image chunk to be kept")
+	}
+	if size := binary.LittleEndian.Uint32(result.Data[4:]); int(size) != len(result.Data)-8 {
+		t.Errorf("Expected the RIFF size %d, but got %d", len(result.Data)-8, size)
+	}
+	if flags := result.Data[20]; flags&0x0C != 0 {
+		t.Errorf("Expected the metadata flags to be cleared, but got %#x", flags)
+	}
+	if result.Width != 75 || result.Height != 100 || len(result.Variants) != 1 {
+		t.Fatalf("Expected a 75x100 image with 1 variant, but got %dx%d and %d", result.Width, result.Height, len(result.Variants))
+	}
+	thumb, err := png.Decode(bytes.NewReader(result.Variants[0].Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := thumb.Bounds(); b.Dx() != 37 || b.Dy() != 50 || result.Variants[0].ContentType != "image/png" {
+		t.Errorf("Expected a 37x50 png thumbnail, but got %dx%d %s", b.Dx(), b.Dy(), result.Variants[0].ContentType)
+	}
+
+	if _, err := Process("image/webp", data[:10], nil, 1<<20); err != ErrMalformed {
+		t.Errorf("Expected %v, but got %v", ErrMalformed, err)
+	}
+}
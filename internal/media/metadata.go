@@ -0,0 +1,272 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrMalformed = errors.New("malformed image data")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// webpMetadataChunks are dropped from uploaded WebPs, along with their flags in the VP8X header.
+var webpMetadataChunks = map[string]byte{"EXIF": 0x08, "XMP ": 0x04}
+
+// pngMetadataChunks are dropped from uploaded PNGs, none of them affect how the image looks.
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripJPEG removes the APPn segments other than JFIF, Adobe and ICC profiles (EXIF, XMP, ...) and
+// the comments without re-encoding the image. It returns the EXIF orientation found on the way, 1 if none.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, ErrMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	orientation := 1
+
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, 0, ErrMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA {
+			// start of scan, the entropy coded data and everything after is kept as is
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, ErrMalformed
+		}
+		segment := data[i+4 : end]
+
+		switch {
+		case marker == 0xE1:
+			if o, ok := exifOrientation(segment); ok {
+				orientation = o
+			}
+		case marker == 0xFE:
+		case marker >= 0xE0 && marker <= 0xEF && !keepAPPSegment(marker, segment):
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return nil, 0, ErrMalformed
+}
+
+func keepAPPSegment(marker byte, segment []byte) bool {
+	switch marker {
+	case 0xE0:
+		return bytes.HasPrefix(segment, []byte("JFIF\x00"))
+	case 0xE2:
+		return bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00"))
+	case 0xEE:
+		return bytes.HasPrefix(segment, []byte("Adobe"))
+	}
+	return false
+}
+
+// exifOrientation reads the orientation tag from the IFD0 of an APP1 EXIF segment.
+func exifOrientation(segment []byte) (int, bool) {
+	if !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+		return 0, false
+	}
+	tiff := segment[6:]
+	if len(tiff) < 8 {
+		return 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 0, false
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 0, false
+			}
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// stripPNG drops the metadata chunks of a PNG, the remaining chunks keep their own checksums.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrMalformed
+		}
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		kind := string(data[i+4 : i+8])
+		if !pngMetadataChunks[kind] {
+			out.Write(data[i:end])
+		}
+		i = end
+		if kind == "IEND" {
+			break
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// stripWebP drops the EXIF and XMP chunks of a WebP and clears their flags in the VP8X chunk, the RIFF size
+// is rewritten to match.
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrMalformed
+	}
+	size := int(binary.LittleEndian.Uint32(data[4:]))
+	if size < 4 || size+8 > len(data) {
+		return nil, ErrMalformed
+	}
+	data = data[:size+8]
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	var dropped byte
+	vp8x := -1
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, ErrMalformed
+		}
+		kind := string(data[i : i+4])
+		length := int(binary.LittleEndian.Uint32(data[i+4:]))
+		// chunks are padded to an even length
+		end := i + 8 + length + length&1
+		if length < 0 || end > len(data) {
+			return nil, ErrMalformed
+		}
+		if flag, ok := webpMetadataChunks[kind]; ok {
+			dropped |= flag
+		} else {
+			if kind == "VP8X" && length > 0 {
+				vp8x = out.Len() + 8
+			}
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	result := out.Bytes()
+	if vp8x >= 0 {
+		result[vp8x] &^= 0x08 | 0x04
+	}
+	binary.LittleEndian.PutUint32(result[4:], uint32(len(result)-8))
+	return result, nil
+}
+
+// stripGIF drops the comment extensions and the application extensions other than the looping one (XMP, ...).
+// The image data is copied as is.
+func stripGIF(data []byte) ([]byte, error) {
+	if len(data) < 13 || (string(data[:6]) != "GIF87a" && string(data[:6]) != "GIF89a") {
+		return nil, ErrMalformed
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&0x07 + 1)
+	}
+	if i > len(data) {
+		return nil, ErrMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:i])
+
+	for i < len(data) {
+		start := i
+		switch data[i] {
+		case 0x3B:
+			// trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21:
+			if i+2 > len(data) {
+				return nil, ErrMalformed
+			}
+			label := data[i+1]
+			end, err := gifSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			if label == 0xFE || (label == 0xFF && !keepGIFApplication(data[start+2:end])) {
+				continue
+			}
+		case 0x2C:
+			if i+10 > len(data) {
+				return nil, ErrMalformed
+			}
+			i += 10
+			if data[start+9]&0x80 != 0 {
+				i += 3 << (data[start+9]&0x07 + 1)
+			}
+			// the LZW minimum code size precedes the image data
+			end, err := gifSubBlocks(data, i+1)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		default:
+			return nil, ErrMalformed
+		}
+		out.Write(data[start:i])
+	}
+	return nil, ErrMalformed
+}
+
+// keepGIFApplication keeps the NETSCAPE2.0 and ANIMEXTS1.0 extensions that set how often an animation loops.
+func keepGIFApplication(blocks []byte) bool {
+	return len(blocks) >= 12 && blocks[0] == 11 &&
+		(string(blocks[1:12]) == "NETSCAPE2.0" || string(blocks[1:12]) == "ANIMEXTS1.0")
+}
+
+// gifSubBlocks returns the offset after the sub-blocks starting at i and their terminator.
+func gifSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, ErrMalformed
+		}
+		n := int(data[i])
+		i++
+		if n == 0 {
+			return i, nil
+		}
+		i += n
+	}
+}
@@ -0,0 +1,88 @@
+package media
+
+import (
+	"image"
+	"image/draw"
+)
+
+// fit returns the size of w x h scaled down to fit in a maxSize square, keeping the aspect ratio.
+func fit(w, h, maxSize int) (int, int) {
+	if w <= maxSize && h <= maxSize {
+		return w, h
+	}
+	if w >= h {
+		return maxSize, max(1, h*maxSize/w)
+	}
+	return max(1, w*maxSize/h), maxSize
+}
+
+// resize scales src down to w x h by averaging the source pixels covered by each destination pixel.
+func resize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint32(p[0])
+					g += uint32(p[1])
+					bl += uint32(p[2])
+					a += uint32(p[3])
+					n++
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(bl/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation (1 to 8) so the image is displayed upright without the tag.
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := sw, sh
+	if orientation >= 5 {
+		dw, dh = sh, sw
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = sw-1-x, y
+			case 3:
+				dx, dy = sw-1-x, sh-1-y
+			case 4:
+				dx, dy = x, sh-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = sh-1-y, x
+			case 7:
+				dx, dy = sh-1-y, sw-1-x
+			case 8:
+				dx, dy = y, sw-1-x
+			}
+			copy(dst.Pix[dy*dst.Stride+dx*4:dy*dst.Stride+dx*4+4], src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4])
+		}
+	}
+	return dst
+}
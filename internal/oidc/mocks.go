@@ -0,0 +1,158 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MockServer is an in-process OpenID provider for tests. Its authorization endpoint logs in Identity right away
+// and redirects back with a code, its token endpoint checks the PKCE verifier and the client credentials.
+type MockServer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+	Identity     Identity
+	// Audience overrides the aud claim of the ID tokens when set.
+	Audience string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	nonce       string
+	challenge   string
+	redirectURI string
+}
+
+func NewMockServer(clientID, clientSecret string) (*MockServer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	m := &MockServer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Identity:     Identity{Subject: "mock-subject", Email: "mock@example.com", EmailVerified: true, Name: "Mock User"},
+		key:          key,
+		codes:        make(map[string]mockGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("GET /authorize", m.authorize)
+	mux.HandleFunc("POST /token", m.token)
+	mux.HandleFunc("GET /jwks", m.jwks)
+	m.Server = httptest.NewServer(mux)
+	return m, nil
+}
+
+func (m *MockServer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeMockJSON(w, http.StatusOK, map[string]any{
+		"issuer":                           m.URL,
+		"authorization_endpoint":           m.URL + "/authorize",
+		"token_endpoint":                   m.URL + "/token",
+		"jwks_uri":                         m.URL + "/jwks",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (m *MockServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	code := base64.RawURLEncoding.EncodeToString(b)
+	m.mu.Lock()
+	m.codes[code] = mockGrant{nonce: q.Get("nonce"), challenge: q.Get("code_challenge"), redirectURI: q.Get("redirect_uri")}
+	m.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *MockServer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != m.ClientID || secret != m.ClientSecret {
+		writeMockJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	m.mu.Lock()
+	grant, ok := m.codes[code]
+	delete(m.codes, code)
+	m.mu.Unlock()
+	if !ok || grant.redirectURI != r.PostFormValue("redirect_uri") ||
+		CodeChallenge(r.PostFormValue("code_verifier")) != grant.challenge {
+		writeMockJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	aud := m.ClientID
+	if m.Audience != "" {
+		aud = m.Audience
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                m.URL,
+		"sub":                m.Identity.Subject,
+		"aud":                aud,
+		"exp":                now.Add(time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              grant.nonce,
+		"email":              m.Identity.Email,
+		"email_verified":     m.Identity.EmailVerified,
+		"name":               m.Identity.Name,
+		"preferred_username": m.Identity.PreferredUsername,
+		"picture":            m.Identity.Picture,
+	})
+	token.Header["kid"] = "mock"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeMockJSON(w, http.StatusOK, map[string]string{"access_token": "mock", "token_type": "Bearer", "id_token": idToken})
+}
+
+func (m *MockServer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeMockJSON(w, http.StatusOK, map[string]any{"keys": []jwk{{
+		Kty: "RSA",
+		Kid: "mock",
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func writeMockJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,331 @@
+// Package oidc is a minimal OpenID Connect relying party: it discovers a provider, sends users to it with the
+// authorization code flow and PKCE, and validates the ID token of the returned code.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	maxResponseSize = 1 << 20
+	// keysRefreshInterval limits how often a token with an unknown kid makes the provider keys load again.
+	keysRefreshInterval = time.Minute
+	leeway              = time.Minute
+)
+
+var (
+	ErrExchange       = errors.New("oidc: the code was not accepted")
+	ErrInvalidIDToken = errors.New("oidc: invalid ID token")
+)
+
+var signingMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}
+
+// Config registers the application as a client of a provider. Scopes defaults to openid, email and profile.
+type Config struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Identity is the user as asserted by the ID token, Subject is unique per provider.
+type Identity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Picture           string
+}
+
+// AuthRequest holds the secrets of one login, kept by the application between the redirect to the provider and
+// the callback.
+type AuthRequest struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// NewAuthRequest generates the state, nonce and PKCE verifier of a login.
+func NewAuthRequest() (AuthRequest, error) {
+	var req AuthRequest
+	for _, v := range []*string{&req.State, &req.Nonce, &req.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return AuthRequest{}, err
+		}
+		*v = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return req, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of the verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is a configured OpenID provider. The discovery document and the signing keys are loaded on first use
+// and cached, a failed discovery is retried on the next call.
+type Provider struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+func New(config Config, client *http.Client) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{config: config, client: client, now: time.Now}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL is the authorization endpoint URL the user is redirected to.
+func (p *Provider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", req.State)
+	params.Set("nonce", req.Nonce)
+	params.Set("code_challenge", CodeChallenge(req.Verifier))
+	params.Set("code_challenge_method", "S256")
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems the code of the callback and returns the identity of its validated ID token.
+func (p *Provider) Exchange(ctx context.Context, code string, req AuthRequest) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", req.Verifier)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	res, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("oidc: token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s %s", ErrExchange, res.Status, body.Error)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: no ID token in the response", ErrInvalidIDToken)
+	}
+	return p.verify(ctx, md, body.IDToken, req.Nonce)
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	AuthorizedParty   string `json:"azp"`
+	Email             string `json:"email"`
+	EmailVerified     any    `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Picture           string `json:"picture"`
+}
+
+// verify checks the signature, issuer, audience, lifetime and nonce of the ID token as required by OpenID Connect
+// Core 3.1.3.7.
+func (p *Provider) verify(ctx context.Context, md *metadata, raw, nonce string) (*Identity, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, md, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(md.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(leeway),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%w: authorized party %q", ErrInvalidIDToken, claims.AuthorizedParty)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	identity := &Identity{
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		Name:              claims.Name,
+		PreferredUsername: claims.PreferredUsername,
+		Picture:           claims.Picture,
+	}
+	// Some providers send email_verified as a string.
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	return identity, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &md); err != nil {
+		return nil, fmt.Errorf("oidc: discovery of %s: %w", p.config.Name, err)
+	}
+	if md.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: discovery of %s: issuer %q does not match %q", p.config.Name, md.Issuer, p.config.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: discovery of %s: missing endpoints", p.config.Name)
+	}
+	p.metadata = &md
+	return p.metadata, nil
+}
+
+// key returns the provider key of the kid, loading the key set again when the kid is unknown. A token without a
+// kid can only be verified when the provider has a single key.
+func (p *Provider) key(ctx context.Context, md *metadata, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if p.now().Sub(p.keysFetchedAt) < keysRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, md.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if public, err := k.publicKey(); err == nil {
+			keys[k.Kid] = public
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, data any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(data)
+}
+
+// supported reports whether the alg is one of the ID token signing methods this package verifies.
+func supported(alg string) bool {
+	return slices.Contains(signingMethods, alg)
+}
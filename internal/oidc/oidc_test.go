@@ -0,0 +1,107 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// login runs the authorization step against the mock provider and returns the code it redirects back with.
+func login(t *testing.T, p *Provider, req AuthRequest) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("Expected a redirect from the provider, but got %d", res.StatusCode)
+	}
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if callback.Query().Get("state") != req.State {
+		t.Fatalf("Expected the state to come back, but got %q", callback.Query().Get("state"))
+	}
+	return callback.Query().Get("code")
+}
+
+func TestProvider(t *testing.T) {
+	server, err := NewMockServer("client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	config := Config{
+		Name:         "mock",
+		Issuer:       server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app.test/callback",
+	}
+	p := New(config, server.Client())
+
+	t.Run("should log in with code and PKCE", func(t *testing.T) {
+		req, err := NewAuthRequest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		identity, err := p.Exchange(context.Background(), login(t, p, req), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if identity.Subject != "mock-subject" || identity.Email != "mock@example.com" || !identity.EmailVerified {
+			t.Errorf("Unexpected identity %+v", identity)
+		}
+	})
+	t.Run("should refuse a wrong verifier", func(t *testing.T) {
+		req, _ := NewAuthRequest()
+		code := login(t, p, req)
+		req.Verifier = "wrong"
+		if _, err := p.Exchange(context.Background(), code, req); !errors.Is(err, ErrExchange) {
+			t.Errorf("Expected ErrExchange, but got %v", err)
+		}
+	})
+	t.Run("should refuse a wrong nonce", func(t *testing.T) {
+		req, _ := NewAuthRequest()
+		code := login(t, p, req)
+		req.Nonce = "replayed"
+		if _, err := p.Exchange(context.Background(), code, req); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("Expected ErrInvalidIDToken, but got %v", err)
+		}
+	})
+	t.Run("should refuse a token for another client", func(t *testing.T) {
+		server.Audience = "other"
+		defer func() { server.Audience = "" }()
+		req, _ := NewAuthRequest()
+		if _, err := p.Exchange(context.Background(), login(t, p, req), req); !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("Expected ErrInvalidIDToken, but got %v", err)
+		}
+	})
+	t.Run("should refuse a wrong client secret", func(t *testing.T) {
+		config := config
+		config.ClientSecret = "wrong"
+		p := New(config, server.Client())
+		req, _ := NewAuthRequest()
+		if _, err := p.Exchange(context.Background(), login(t, p, req), req); !errors.Is(err, ErrExchange) {
+			t.Errorf("Expected ErrExchange, but got %v", err)
+		}
+	})
+	t.Run("should refuse a provider with another issuer", func(t *testing.T) {
+		config := config
+		config.Issuer = server.URL + "/"
+		p := New(config, server.Client())
+		if _, err := p.AuthCodeURL(context.Background(), AuthRequest{}); err == nil {
+			t.Error("Expected the discovery to fail")
+		}
+	})
+}
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Attachment is a file of a post. Its URL, and those of its variants, are links signed for the viewer that
+// expire, they need no Authorization header.
+type Attachment struct {
+	ID          int64               `json:"id"`
+	PostID      int64               `json:"post_id"`
+	Key         string              `json:"-"`
+	Filename    string              `json:"filename"`
+	ContentType string              `json:"content_type"`
+	Size        int64               `json:"size"`
+	Width       int                 `json:"width,omitempty"`
+	Height      int                 `json:"height,omitempty"`
+	URL         string              `json:"url"`
+	CreatedAt   string              `json:"created_at"`
+	Variants    []AttachmentVariant `json:"variants"`
+}
+
+// AttachmentVariant is a resized rendition of an image attachment, such as its thumbnail.
+type AttachmentVariant struct {
+	Name        string `json:"name"`
+	Key         string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+type AttachmentStorage struct {
+	db *sql.DB
+}
+
+// Create saves the attachments of an upload to one post with their variants, either all of them or none. When the
+// post would have more than max attachments it fails with ErrAttachmentLimit.
+func (a *AttachmentStorage) Create(ctx context.Context, attachments []Attachment, max int) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	return withTx(a.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// Locking the post serializes its uploads, so concurrent requests cannot overshoot the limit.
+		query := `SELECT id FROM posts WHERE id = $1 FOR UPDATE;`
+		var id int64
+		if err := tx.QueryRowContext(ctx, query, attachments[0].PostID).Scan(&id); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		var count int
+		query = `SELECT COUNT(*) FROM post_attachments WHERE post_id = $1;`
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&count); err != nil {
+			return err
+		}
+		if count+len(attachments) > max {
+			return ErrAttachmentLimit
+		}
+
+		for i := range attachments {
+			attachment := &attachments[i]
+			query := `INSERT INTO post_attachments (post_id, storage_key, filename, content_type, size, width, height)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at;`
+			err := tx.QueryRowContext(ctx, query, attachment.PostID, attachment.Key, attachment.Filename,
+				attachment.ContentType, attachment.Size, attachment.Width, attachment.Height).Scan(&attachment.ID, &attachment.CreatedAt)
+			if err != nil {
+				return err
+			}
+
+			query = `INSERT INTO post_attachment_variants (attachment_id, name, storage_key, content_type, width, height, size)
+			VALUES ($1, $2, $3, $4, $5, $6, $7);`
+			for _, variant := range attachment.Variants {
+				_, err := tx.ExecContext(ctx, query, attachment.ID, variant.Name, variant.Key, variant.ContentType,
+					variant.Width, variant.Height, variant.Size)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (a *AttachmentStorage) GetByID(ctx context.Context, attachmentID int64) (*Attachment, error) {
+	var attachment Attachment
+	query := `SELECT id, post_id, storage_key, filename, content_type, size, width, height, created_at FROM post_attachments WHERE id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := a.db.QueryRowContext(ctx, query, attachmentID).Scan(
+		&attachment.ID,
+		&attachment.PostID,
+		&attachment.Key,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.Width,
+		&attachment.Height,
+		&attachment.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	variants, err := getAttachmentVariants(ctx, a.db, []int64{attachment.ID})
+	if err != nil {
+		return nil, err
+	}
+	attachment.Variants = variants[attachment.ID]
+
+	return &attachment, nil
+}
+
+// GetByKey fetches the attachment stored under the blob key, or having a variant stored under it, when the viewer
+// can see its post.
+func (a *AttachmentStorage) GetByKey(ctx context.Context, key string, viewerID int64) (*Attachment, error) {
+	var attachment Attachment
+	query := `SELECT pa.id, pa.post_id, pa.storage_key, pa.filename, pa.content_type, pa.size, pa.width, pa.height, pa.created_at
+		FROM post_attachments pa
+		JOIN posts p ON p.id = pa.post_id
+		WHERE (pa.storage_key = $1 OR EXISTS (
+			SELECT 1 FROM post_attachment_variants v WHERE v.attachment_id = pa.id AND v.storage_key = $1))
+		AND p.deleted_at IS NULL AND ` + visibleTo("$2") + `;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := a.db.QueryRowContext(ctx, query, key, viewerID).Scan(
+		&attachment.ID,
+		&attachment.PostID,
+		&attachment.Key,
+		&attachment.Filename,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.Width,
+		&attachment.Height,
+		&attachment.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &attachment, nil
+}
+
+func (a *AttachmentStorage) GetByPostID(ctx context.Context, postID int64) ([]Attachment, error) {
+	attachments, err := getAttachments(ctx, a.db, []int64{postID})
+	if err != nil {
+		return nil, err
+	}
+	return attachments[postID], nil
+}
+
+func (a *AttachmentStorage) Delete(ctx context.Context, attachmentID int64) error {
+	query := `DELETE FROM post_attachments WHERE id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := a.db.ExecContext(ctx, query, attachmentID)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetKeysDeletedBefore lists the blob keys of the attachments and their variants that Posts.Purge is going to remove.
+func (a *AttachmentStorage) GetKeysDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	query := `SELECT pa.storage_key FROM post_attachments pa
+		JOIN posts p ON p.id = pa.post_id
+		WHERE p.deleted_at < $1
+		UNION ALL
+		SELECT v.storage_key FROM post_attachment_variants v
+		JOIN post_attachments pa ON pa.id = v.attachment_id
+		JOIN posts p ON p.id = pa.post_id
+		WHERE p.deleted_at < $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := a.db.QueryContext(ctx, query, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// getAttachments loads the attachments of the given posts, every requested post gets a non nil slice.
+func getAttachments(ctx context.Context, db *sql.DB, postIDs []int64) (map[int64][]Attachment, error) {
+	query := `SELECT id, post_id, storage_key, filename, content_type, size, width, height, created_at FROM post_attachments
+		WHERE post_id = ANY($1)
+		ORDER BY id;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	attachments := make(map[int64][]Attachment, len(postIDs))
+	for _, id := range postIDs {
+		attachments[id] = []Attachment{}
+	}
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int64{}
+	for rows.Next() {
+		var attachment Attachment
+		if err := rows.Scan(
+			&attachment.ID,
+			&attachment.PostID,
+			&attachment.Key,
+			&attachment.Filename,
+			&attachment.ContentType,
+			&attachment.Size,
+			&attachment.Width,
+			&attachment.Height,
+			&attachment.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments[attachment.PostID] = append(attachments[attachment.PostID], attachment)
+		ids = append(ids, attachment.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	variants, err := getAttachmentVariants(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for postID := range attachments {
+		for i := range attachments[postID] {
+			attachments[postID][i].Variants = variants[attachments[postID][i].ID]
+		}
+	}
+
+	return attachments, nil
+}
+
+// getAttachmentVariants loads the variants of the given attachments, every requested attachment gets a non nil slice.
+func getAttachmentVariants(ctx context.Context, db *sql.DB, attachmentIDs []int64) (map[int64][]AttachmentVariant, error) {
+	query := `SELECT attachment_id, name, storage_key, content_type, width, height, size FROM post_attachment_variants
+		WHERE attachment_id = ANY($1)
+		ORDER BY width;`
+
+	variants := make(map[int64][]AttachmentVariant, len(attachmentIDs))
+	for _, id := range attachmentIDs {
+		variants[id] = []AttachmentVariant{}
+	}
+	if len(attachmentIDs) == 0 {
+		return variants, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(attachmentIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var attachmentID int64
+		var variant AttachmentVariant
+		if err := rows.Scan(
+			&attachmentID,
+			&variant.Name,
+			&variant.Key,
+			&variant.ContentType,
+			&variant.Width,
+			&variant.Height,
+			&variant.Size); err != nil {
+			return nil, err
+		}
+		variants[attachmentID] = append(variants[attachmentID], variant)
+	}
+
+	return variants, rows.Err()
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestAttachmentLimit(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	owner := createTestUsers(t, db, "uploader")[0]
+	post := &Post{Title: "attachments", Content: "attachments", Format: ContentFormatPlain, UserID: owner.ID,
+		Tags: []string{}, Status: PostStatusPublished, Visibility: PostVisibilityPublic}
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent uploads of two attachments each, only two of them fit in a limit of four
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attachments := []Attachment{}
+			for j := range 2 {
+				attachments = append(attachments, Attachment{PostID: post.ID, Key: fmt.Sprintf("posts/%d/%d_%d.png", post.ID, i, j),
+					Filename: "a.png", ContentType: "image/png"})
+			}
+			errs[i] = s.Attachments.Create(ctx, attachments, 4)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrAttachmentLimit):
+			t.Fatal(err)
+		}
+	}
+	attachments, err := s.Attachments.GetByPostID(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 2 || len(attachments) != 4 {
+		t.Errorf("Expected 2 uploads with 4 attachments, but got %d with %d", created, len(attachments))
+	}
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type BookmarkStorage struct {
+	db *sql.DB
+}
+
+func (b *BookmarkStorage) Add(ctx context.Context, postID, userID int64) error {
+	query := `INSERT INTO bookmarks (user_id, post_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := b.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *BookmarkStorage) Remove(ctx context.Context, postID, userID int64) error {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND post_id = $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := b.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetByUserID lists the readable posts the user bookmarked, ordered by when they were saved and searched and
+// filtered like the feed.
+func (b *BookmarkStorage) GetByUserID(ctx context.Context, userID int64, pagQ PaginateQuery) ([]PostForFeed, error) {
+	query := `
+	SELECT
+		p.id, p.user_id, p.title, p.content, p.format, p.created_at, p.version, p.tags, p.status, p.publish_at, p.visibility, p.quoted_post_id,
+		u.username, COUNT(c.id) AS comments_count
+	FROM bookmarks b
+	JOIN posts p ON p.id = b.post_id
+	LEFT JOIN comments c ON c.post_id = p.id
+	LEFT JOIN users u ON p.user_id = u.id
+	WHERE b.user_id = $1
+	AND p.deleted_at IS NULL
+	AND ` + visibleTo("$1") + `
+	AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
+	AND (p.tags @> $5 OR array_length($5, 1) IS NULL)
+	GROUP BY p.id, u.username, b.created_at
+	ORDER BY b.created_at ` + pagQ.Sort + `
+	LIMIT $2 OFFSET $3;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := b.db.QueryContext(ctx, query, userID, pagQ.Limit, pagQ.Offset, pagQ.Search, pq.Array(pagQ.Tags))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	posts := []PostForFeed{}
+	for rows.Next() {
+		var p PostForFeed
+		if err := rows.Scan(
+			&p.Post.ID,
+			&p.Post.UserID,
+			&p.Post.Title,
+			&p.Post.Content,
+			&p.Post.Format,
+			&p.Post.CreatedAt,
+			&p.Post.Version,
+			pq.Array(&p.Post.Tags),
+			&p.Post.Status,
+			&p.Post.PublishAt,
+			&p.Post.Visibility,
+			&p.Post.QuotedPostID,
+			&p.Post.User.Username,
+			&p.CommentCount); err != nil {
+			return nil, err
+		}
+		p.Post.User.ID = p.Post.UserID
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadFeedDetails(ctx, b.db, posts, userID); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// getBookmarked tells which of the given posts the user bookmarked.
+func getBookmarked(ctx context.Context, db *sql.DB, postIDs []int64, userID int64) (map[int64]bool, error) {
+	query := `SELECT post_id FROM bookmarks WHERE user_id = $1 AND post_id = ANY($2);`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	bookmarked := make(map[int64]bool, len(postIDs))
+	rows, err := db.QueryContext(ctx, query, userID, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID int64
+		if err := rows.Scan(&postID); err != nil {
+			return nil, err
+		}
+		bookmarked[postID] = true
+	}
+
+	return bookmarked, rows.Err()
+}
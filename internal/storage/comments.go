@@ -3,23 +3,98 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID          int64     `json:"id"`
+	PostID      int64     `json:"post_id"`
+	UserID      int64     `json:"user_id"`
+	ParentID    *int64    `json:"parent_id"`
+	Depth       int       `json:"depth"`
+	Content     string    `json:"content"`
+	Format      string    `json:"format"`
+	ContentHTML string    `json:"content_html"`
+	CreatedAt   string    `json:"created_at"`
+	User        User      `json:"user"`
+	ReplyCount  int       `json:"reply_count"`
+	Mentions    []Mention `json:"mentions"`
+	Replies     []Comment `json:"replies,omitempty"`
+}
+
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	Total      int       `json:"total"`
+	NextCursor string    `json:"next_cursor,omitempty"`
 }
 
 type CommentStorage struct {
 	db *sql.DB
 }
 
+// Create saves the comment together with the mentions in its content, see saveMentions.
+func (c CommentStorage) Create(ctx context.Context, comment *Comment) error {
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO comments (post_id, user_id, parent_id, depth, content, format) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, comment.PostID, comment.UserID, comment.ParentID, comment.Depth,
+			comment.Content, comment.Format).Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		comment.Mentions, err = saveMentions(ctx, tx, comment.PostID, &comment.ID, comment.Mentions)
+		return err
+	})
+}
+
+func (c CommentStorage) GetByID(ctx context.Context, commentID int64) (*Comment, error) {
+	var comment Comment
+	query := `SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.content, c.format, c.created_at, users.username, users.id FROM comments c
+		JOIN users on users.id = c.user_id
+		WHERE c.id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := c.db.QueryRowContext(ctx, query, commentID).Scan(
+		&comment.ID,
+		&comment.PostID,
+		&comment.UserID,
+		&comment.ParentID,
+		&comment.Depth,
+		&comment.Content,
+		&comment.Format,
+		&comment.CreatedAt,
+		&comment.User.Username,
+		&comment.User.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	mentions, err := getCommentMentions(ctx, c.db, []int64{comment.ID})
+	if err != nil {
+		return nil, err
+	}
+	comment.Mentions = mentions[comment.ID]
+
+	return &comment, nil
+}
+
 func (c CommentStorage) GetByPostID(ctx context.Context, postId int64) ([]Comment, error) {
-	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id  FROM comments c
+	query := `SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.content, c.format, c.created_at, users.username, users.id  FROM comments c
 		JOIN users on users.id = c.user_id
 		WHERE c.post_id = $1
 		ORDER BY c.created_at DESC;`
@@ -38,7 +113,10 @@ func (c CommentStorage) GetByPostID(ctx context.Context, postId int64) ([]Commen
 			&comment.ID,
 			&comment.PostID,
 			&comment.UserID,
+			&comment.ParentID,
+			&comment.Depth,
 			&comment.Content,
+			&comment.Format,
 			&comment.CreatedAt,
 			&comment.User.Username,
 			&comment.User.ID); err != nil {
@@ -47,6 +125,315 @@ func (c CommentStorage) GetByPostID(ctx context.Context, postId int64) ([]Commen
 		comments = append(comments, comment)
 
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := withCommentMentions(ctx, c.db, comments); err != nil {
+		return nil, err
+	}
 
 	return comments, nil
 }
+
+// GetPageByPostID returns one page of a post's top level comments with their nested replies.
+//
+// The most_replied pages rank the comments by their reply counts as of the snapshot time of the first page, which
+// the cursor carries along. Replies written while a client pages do not reorder the pages, so no comment is
+// skipped or repeated, and the comments written after the snapshot only show up in a new listing.
+func (c CommentStorage) GetPageByPostID(ctx context.Context, postID int64, cq CommentQuery) (*CommentPage, error) {
+	var after, snapshot string
+	var afterID int64
+	if cq.Cursor != "" {
+		var err error
+		after, snapshot, afterID, err = decodeCommentCursor(cq.Cursor, cq.Sort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	order := "t.created_at DESC, t.id DESC"
+	where := "(t.created_at, t.id) < ($3::timestamptz, $4)"
+	switch cq.Sort {
+	case CommentSortOldest:
+		order = "t.created_at ASC, t.id ASC"
+		where = "(t.created_at, t.id) > ($3::timestamptz, $4)"
+	case CommentSortMostReplied:
+		order = "t.reply_count DESC, t.id DESC"
+		where = "(t.reply_count, t.id) < ($3::bigint, $4)"
+	}
+	args := []any{postID, cq.Limit + 1}
+	if cq.Cursor != "" {
+		args = append(args, after, afterID)
+	} else {
+		where = "TRUE"
+	}
+
+	replyCount := "(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id)"
+	asOf := ""
+	if cq.Sort == CommentSortMostReplied {
+		if snapshot == "" {
+			snapshot = time.Now().UTC().Format(time.RFC3339Nano)
+		}
+		args = append(args, snapshot)
+		n := len(args)
+		replyCount = fmt.Sprintf("(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND r.created_at <= $%d::timestamptz)", n)
+		asOf = fmt.Sprintf(" AND c.created_at <= $%d::timestamptz", n)
+	}
+
+	query := `SELECT t.id, t.post_id, t.user_id, t.parent_id, t.depth, t.content, t.format, t.created_at, t.username, t.reply_count FROM (
+			SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.content, c.format, c.created_at, users.username,
+				` + replyCount + ` AS reply_count
+			FROM comments c
+			JOIN users on users.id = c.user_id
+			WHERE c.post_id = $1 AND c.parent_id IS NULL` + asOf + `
+		) t
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT $2;`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	page := &CommentPage{}
+	if err := c.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM comments WHERE post_id = $1;`, postID).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	roots := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		if err := rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.UserID,
+			&comment.ParentID,
+			&comment.Depth,
+			&comment.Content,
+			&comment.Format,
+			&comment.CreatedAt,
+			&comment.User.Username,
+			&comment.ReplyCount); err != nil {
+			return nil, err
+		}
+		comment.User.ID = comment.UserID
+		roots = append(roots, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(roots) > cq.Limit {
+		roots = roots[:cq.Limit]
+		page.NextCursor = encodeCommentCursor(roots[len(roots)-1], cq.Sort, snapshot)
+	}
+
+	replies, err := c.getReplies(ctx, roots)
+	if err != nil {
+		return nil, err
+	}
+	comments := append(roots, replies...)
+	if err := withCommentMentions(ctx, c.db, comments); err != nil {
+		return nil, err
+	}
+	page.Comments = NestComments(comments)
+
+	return page, nil
+}
+
+// getReplies loads every reply below the given comments, newest first.
+func (c CommentStorage) getReplies(ctx context.Context, roots []Comment) ([]Comment, error) {
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, len(roots))
+	for i, root := range roots {
+		ids[i] = root.ID
+	}
+
+	query := `WITH RECURSIVE thread AS (
+			SELECT id FROM comments WHERE parent_id = ANY($1)
+			UNION ALL
+			SELECT c.id FROM comments c JOIN thread ON c.parent_id = thread.id
+		)
+		SELECT c.id, c.post_id, c.user_id, c.parent_id, c.depth, c.content, c.format, c.created_at, users.username,
+			(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id) AS reply_count
+		FROM comments c
+		JOIN thread ON thread.id = c.id
+		JOIN users on users.id = c.user_id
+		ORDER BY c.created_at DESC, c.id DESC;`
+
+	rows, err := c.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	replies := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		if err := rows.Scan(
+			&comment.ID,
+			&comment.PostID,
+			&comment.UserID,
+			&comment.ParentID,
+			&comment.Depth,
+			&comment.Content,
+			&comment.Format,
+			&comment.CreatedAt,
+			&comment.User.Username,
+			&comment.ReplyCount); err != nil {
+			return nil, err
+		}
+		comment.User.ID = comment.UserID
+		replies = append(replies, comment)
+	}
+
+	return replies, rows.Err()
+}
+
+// Update saves the comment's content, the stored mentions are replaced by comment.Mentions.
+func (c CommentStorage) Update(ctx context.Context, comment *Comment) error {
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE comments SET content = $1, format = $2 WHERE id = $3;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		res, err := tx.ExecContext(ctx, query, comment.Content, comment.Format, comment.ID)
+		if err != nil {
+			return err
+		}
+
+		rowsCount, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsCount == 0 {
+			return ErrNotFound
+		}
+
+		comment.Mentions, err = saveMentions(ctx, tx, comment.PostID, &comment.ID, comment.Mentions)
+		return err
+	})
+}
+
+func (c CommentStorage) Delete(ctx context.Context, commentID int64) error {
+	query := `DELETE FROM comments WHERE id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := c.db.ExecContext(ctx, query, commentID)
+	if err != nil {
+		return err
+	}
+
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount == 0 {
+		return ErrNotFound
+	} else if rowsCount != 1 {
+		return ErrTooMuchChanged
+	}
+	return nil
+}
+
+// withCommentMentions loads the mentions of the comments in place.
+func withCommentMentions(ctx context.Context, db *sql.DB, comments []Comment) error {
+	ids := make([]int64, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	mentions, err := getCommentMentions(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Mentions = mentions[comments[i].ID]
+	}
+	return nil
+}
+
+// NestComments arranges a flat list of a post's comments, as returned by GetByPostID,
+// into reply threads. Top level comments keep their order, replies are oldest first.
+func NestComments(comments []Comment) []Comment {
+	children := make(map[int64][]Comment)
+	roots := []Comment{}
+	for i := len(comments) - 1; i >= 0; i-- {
+		if comments[i].ParentID != nil {
+			children[*comments[i].ParentID] = append(children[*comments[i].ParentID], comments[i])
+		}
+	}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			roots = append(roots, attachReplies(comment, children))
+		}
+	}
+	return roots
+}
+
+func attachReplies(comment Comment, children map[int64][]Comment) Comment {
+	for _, reply := range children[comment.ID] {
+		comment.Replies = append(comment.Replies, attachReplies(reply, children))
+	}
+	return comment
+}
+
+func encodeCommentCursor(comment Comment, sort, snapshot string) string {
+	key := comment.CreatedAt
+	if sort == CommentSortMostReplied {
+		key = strconv.Itoa(comment.ReplyCount) + "@" + snapshot
+	}
+	return encodeCursor(key, comment.ID)
+}
+
+// decodeCommentCursor returns the sort key and, for most_replied cursors, the snapshot time of the listing.
+func decodeCommentCursor(cursor, sort string) (string, string, int64, error) {
+	key, afterID, err := decodeCursor(cursor)
+	if err != nil {
+		return "", "", 0, err
+	}
+	snapshot := ""
+	if sort == CommentSortMostReplied {
+		var ok bool
+		key, snapshot, ok = strings.Cut(key, "@")
+		if !ok {
+			return "", "", 0, ErrInvalidCursor
+		}
+		_, err = strconv.Atoi(key)
+		if err == nil {
+			_, err = time.Parse(time.RFC3339Nano, snapshot)
+		}
+	} else {
+		_, err = time.Parse(time.RFC3339Nano, key)
+	}
+	if err != nil {
+		return "", "", 0, ErrInvalidCursor
+	}
+	return key, snapshot, afterID, nil
+}
+
+// encodeCursor packs the sort key and the ID of the last row of a page into an opaque keyset cursor.
+func encodeCursor(key string, id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s|%d", key, id)))
+}
+
+// decodeCursor unpacks a cursor made by encodeCursor, the caller validates the key.
+func decodeCursor(cursor string) (string, int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	key, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return "", 0, ErrInvalidCursor
+	}
+	afterID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	return key, afterID, nil
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Relationship tells how the viewer and another user follow each other.
+type Relationship struct {
+	FollowsYou bool `json:"follows_you"`
+	YouFollow  bool `json:"you_follow"`
+}
+
+// UserStats are the counters shown on a profile, PostCount only counts the posts the viewer can read.
+type UserStats struct {
+	FollowerCount  int
+	FollowingCount int
+	PostCount      int
+	Relationship   Relationship
+}
+
+type FollowEntry struct {
+	User         User         `json:"user"`
+	FollowedAt   time.Time    `json:"followed_at"`
+	Relationship Relationship `json:"relationship"`
+}
+
+type FollowPage struct {
+	Users      []FollowEntry `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// GetStats counts the followers, followed users and readable posts of the user and looks up how the user and the
+// viewer follow each other.
+func (u *UserStorage) GetStats(ctx context.Context, userID, viewerID int64) (*UserStats, error) {
+	query := `SELECT
+			(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+			(SELECT COUNT(*) FROM followers WHERE follower_id = $1),
+			(SELECT COUNT(*) FROM posts p WHERE p.user_id = $1 AND p.deleted_at IS NULL AND ` + visibleTo("$2") + `),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = $1),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $1 AND follower_id = $2);`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var stats UserStats
+	err := u.db.QueryRowContext(ctx, query, userID, viewerID).Scan(
+		&stats.FollowerCount,
+		&stats.FollowingCount,
+		&stats.PostCount,
+		&stats.Relationship.FollowsYou,
+		&stats.Relationship.YouFollow)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetFollowers lists the active users following the user, most recent follow first.
+func (u *UserStorage) GetFollowers(ctx context.Context, userID, viewerID int64, fq FollowQuery) (*FollowPage, error) {
+	return u.getFollows(ctx, "f.follower_id", "f.user_id", userID, viewerID, fq)
+}
+
+// GetFollowing lists the active users the user follows, most recent follow first.
+func (u *UserStorage) GetFollowing(ctx context.Context, userID, viewerID int64, fq FollowQuery) (*FollowPage, error) {
+	return u.getFollows(ctx, "f.user_id", "f.follower_id", userID, viewerID, fq)
+}
+
+// getFollows pages through the followers rows where the user is in the by column, listing the users of the
+// listed column. Pages are keyed on the follow time and the listed user ID.
+func (u *UserStorage) getFollows(ctx context.Context, listed, by string, userID, viewerID int64, fq FollowQuery) (*FollowPage, error) {
+	args := []any{userID, viewerID, fq.Limit + 1}
+	where := "TRUE"
+	if fq.Cursor != "" {
+		after, afterID, err := decodeCursor(fq.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		afterTime, err := time.Parse(time.RFC3339Nano, after)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		where = "(f.created_at, u.id) < ($4::timestamptz, $5)"
+		args = append(args, afterTime, afterID)
+	}
+
+	query := `SELECT u.id, u.username, u.display_name, u.avatar_url, f.created_at,
+			EXISTS (SELECT 1 FROM followers WHERE user_id = $2 AND follower_id = u.id),
+			EXISTS (SELECT 1 FROM followers WHERE user_id = u.id AND follower_id = $2)
+		FROM followers f
+		JOIN users u ON u.id = ` + listed + `
+		WHERE ` + by + ` = $1 AND u.is_active = TRUE AND ` + where + `
+		ORDER BY f.created_at DESC, u.id DESC
+		LIMIT $3;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := u.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	page := &FollowPage{Users: []FollowEntry{}}
+	for rows.Next() {
+		var entry FollowEntry
+		if err := rows.Scan(
+			&entry.User.ID,
+			&entry.User.Username,
+			&entry.User.DisplayName,
+			&entry.User.AvatarURL,
+			&entry.FollowedAt,
+			&entry.Relationship.FollowsYou,
+			&entry.Relationship.YouFollow); err != nil {
+			return nil, err
+		}
+		entry.User.Is_Active = true
+		page.Users = append(page.Users, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > fq.Limit {
+		page.Users = page.Users[:fq.Limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = encodeCursor(last.FollowedAt.Format(time.RFC3339Nano), last.User.ID)
+	}
+	return page, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestFollowersPages(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	users := createTestUsers(t, db, "followed", "first", "second", "third")
+	followed := users[0]
+	ids := []int64{}
+	for _, follower := range users[1:] {
+		if err := s.Users.Follow(ctx, followed.ID, follower.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, follower.ID)
+	}
+	if _, err := db.Exec(`UPDATE users SET is_active = TRUE WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[int64]bool{}
+	fq := FollowQuery{Limit: 2}
+	for page := 0; ; page++ {
+		followers, err := s.Users.GetFollowers(ctx, followed.ID, followed.ID, fq)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range followers.Users {
+			if seen[entry.User.ID] || entry.FollowedAt.IsZero() {
+				t.Errorf("Unexpected entry %+v on page %d", entry, page)
+			}
+			seen[entry.User.ID] = true
+		}
+		if followers.NextCursor == "" {
+			break
+		}
+		fq.Cursor = followers.NextCursor
+	}
+	if len(seen) != len(ids) {
+		t.Errorf("Expected %d followers, but got %v", len(ids), seen)
+	}
+}
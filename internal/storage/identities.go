@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OAuthState is a login in progress at an OpenID provider, looked up by the hash of its state parameter when the
+// provider redirects back.
+type OAuthState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	DeviceLabel  string
+}
+
+type IdentityStorage struct {
+	db *sql.DB
+}
+
+// GetUser returns the active user linked to the subject of the provider.
+func (s *IdentityStorage) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+	query := `SELECT u.id, u.username, u.email, u.created_at, u.is_active, u.role_id
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.is_active = TRUE;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var user User
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.CreatedAt,
+		&user.Is_Active,
+		&user.Role_id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+// Link connects the subject of the provider to an existing user, a subject linked before fails with ErrConflict.
+func (s *IdentityStorage) Link(ctx context.Context, userID int64, provider, subject, email string) error {
+	query := `INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4);`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, provider, subject, userID, email)
+	return identityError(err)
+}
+
+// CreateUser creates an activated user linked to the subject of the provider. A taken email fails with
+// ErrEmailTaken, a taken username or a linked subject with ErrConflict.
+func (s *IdentityStorage) CreateUser(ctx context.Context, user *User, provider, subject string) error {
+	return identityError(withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO users (username, email, password, is_active, display_name, avatar_url)
+			VALUES ($1, $2, $3, TRUE, $4, $5) RETURNING id, created_at, role_id;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, user.Username, user.Email, user.Password.hash, user.DisplayName,
+			user.AvatarURL).Scan(&user.ID, &user.CreatedAt, &user.Role_id)
+		if err != nil {
+			return err
+		}
+		user.Is_Active = true
+
+		query = `INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4);`
+		_, err = tx.ExecContext(ctx, query, provider, subject, user.ID, user.Email)
+		return err
+	}))
+}
+
+// CreateState stores a login in progress for exp and drops the expired ones.
+func (s *IdentityStorage) CreateState(ctx context.Context, state *OAuthState, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM oauth_states WHERE expires_at < NOW();`); err != nil {
+			return err
+		}
+		query := `INSERT INTO oauth_states (state_hash, provider, nonce, code_verifier, device_label, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6);`
+		_, err := tx.ExecContext(ctx, query, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier,
+			state.DeviceLabel, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ConsumeState removes and returns the unexpired login of the state hash, so every state is used once.
+func (s *IdentityStorage) ConsumeState(ctx context.Context, stateHash string) (*OAuthState, error) {
+	query := `DELETE FROM oauth_states WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING state_hash, provider, nonce, code_verifier, device_label;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var state OAuthState
+	err := s.db.QueryRowContext(ctx, query, stateHash).Scan(
+		&state.StateHash,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.DeviceLabel)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+func identityError(err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		if pqErr.Constraint == "users_email_key" {
+			return ErrEmailTaken
+		}
+		return ErrConflict
+	}
+	return err
+}
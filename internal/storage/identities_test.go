@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreateIdentityUser(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	// The invited user has not activated yet.
+	invited := createTestUsers(t, db, "invited")[0]
+
+	user := &User{Username: invited.Username + "_oidc", Email: invited.Email}
+	if err := user.Password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Identities.CreateUser(ctx, user, "mock", "subject-"+invited.Username); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken for the email of the invited user, but got %v", err)
+	}
+
+	user = &User{Username: invited.Username, Email: "oidc_" + invited.Email}
+	if err := user.Password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Identities.CreateUser(ctx, user, "mock", "subject-"+invited.Username); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict for the username of the invited user, but got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Mention is an @username in a post or comment that belongs to a user, Start and End are character offsets
+// into the content with End exclusive.
+type Mention struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// MentionItem is a post, or a comment when CommentID is set, that mentions a user.
+type MentionItem struct {
+	PostID      int64  `json:"post_id"`
+	CommentID   *int64 `json:"comment_id"`
+	Title       string `json:"title"`
+	Content     string `json:"content"`
+	Format      string `json:"format"`
+	ContentHTML string `json:"content_html"`
+	User        User   `json:"user"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type MentionStorage struct {
+	db *sql.DB
+}
+
+// GetByUserID lists the readable posts and comments mentioning the user, searched and filtered like the feed.
+func (m *MentionStorage) GetByUserID(ctx context.Context, userID int64, pagQ PaginateQuery) ([]MentionItem, error) {
+	query := `
+	SELECT
+		p.id, c.id, p.title, COALESCE(c.content, p.content), COALESCE(c.format, p.format),
+		u.id, u.username, COALESCE(c.created_at, p.created_at) AS created_at
+	FROM (SELECT DISTINCT post_id, comment_id FROM mentions WHERE user_id = $1) m
+	JOIN posts p ON p.id = m.post_id
+	LEFT JOIN comments c ON c.id = m.comment_id
+	JOIN users u ON u.id = COALESCE(c.user_id, p.user_id)
+	WHERE p.deleted_at IS NULL
+	AND ` + visibleTo("$1") + `
+	AND COALESCE(c.content, p.content) ILIKE '%' || $4 || '%'
+	AND (p.tags @> $5 OR array_length($5, 1) IS NULL)
+	ORDER BY created_at ` + pagQ.Sort + `
+	LIMIT $2 OFFSET $3;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := m.db.QueryContext(ctx, query, userID, pagQ.Limit, pagQ.Offset, pagQ.Search, pq.Array(pagQ.Tags))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []MentionItem{}
+	for rows.Next() {
+		var item MentionItem
+		if err := rows.Scan(
+			&item.PostID,
+			&item.CommentID,
+			&item.Title,
+			&item.Content,
+			&item.Format,
+			&item.User.ID,
+			&item.User.Username,
+			&item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// saveMentions replaces the mentions stored for a post, or for one of its comments when commentID is set.
+// Mentions of unknown or inactive usernames are dropped, the returned ones carry the user IDs.
+func saveMentions(ctx context.Context, tx *sql.Tx, postID int64, commentID *int64, mentions []Mention) ([]Mention, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	query := `DELETE FROM mentions WHERE post_id = $1 AND comment_id IS NOT DISTINCT FROM $2;`
+	if _, err := tx.ExecContext(ctx, query, postID, commentID); err != nil {
+		return nil, err
+	}
+
+	resolved := []Mention{}
+	if len(mentions) == 0 {
+		return resolved, nil
+	}
+	usernames := make([]string, len(mentions))
+	for i, mention := range mentions {
+		usernames[i] = strings.ToLower(mention.Username)
+	}
+
+	// Usernames are unique regardless of case, so @Alice mentions alice.
+	query = `SELECT id, lower(username) FROM users WHERE lower(username) = ANY($1) AND is_active = TRUE;`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	userIDs := map[string]int64{}
+	for rows.Next() {
+		var id int64
+		var username string
+		if err := rows.Scan(&id, &username); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		userIDs[username] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO mentions (post_id, comment_id, user_id, start_offset, end_offset) VALUES ($1, $2, $3, $4, $5);`
+	for _, mention := range mentions {
+		id, ok := userIDs[strings.ToLower(mention.Username)]
+		if !ok {
+			continue
+		}
+		mention.UserID = id
+		if _, err := tx.ExecContext(ctx, query, postID, commentID, mention.UserID, mention.Start, mention.End); err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, mention)
+	}
+
+	return resolved, nil
+}
+
+// getPostMentions loads the mentions in the content of the given posts, every requested post gets a non nil slice.
+func getPostMentions(ctx context.Context, db *sql.DB, postIDs []int64) (map[int64][]Mention, error) {
+	query := `SELECT m.post_id, m.user_id, u.username, m.start_offset, m.end_offset FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.post_id = ANY($1) AND m.comment_id IS NULL
+		ORDER BY m.start_offset;`
+	return getMentions(ctx, db, query, postIDs)
+}
+
+// getCommentMentions loads the mentions of the given comments, every requested comment gets a non nil slice.
+func getCommentMentions(ctx context.Context, db *sql.DB, commentIDs []int64) (map[int64][]Mention, error) {
+	query := `SELECT m.comment_id, m.user_id, u.username, m.start_offset, m.end_offset FROM mentions m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.comment_id = ANY($1)
+		ORDER BY m.start_offset;`
+	return getMentions(ctx, db, query, commentIDs)
+}
+
+func getMentions(ctx context.Context, db *sql.DB, query string, ids []int64) (map[int64][]Mention, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	mentions := make(map[int64][]Mention, len(ids))
+	for _, id := range ids {
+		mentions[id] = []Mention{}
+	}
+	if len(ids) == 0 {
+		return mentions, nil
+	}
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var mention Mention
+		if err := rows.Scan(
+			&id,
+			&mention.UserID,
+			&mention.Username,
+			&mention.Start,
+			&mention.End); err != nil {
+			return nil, err
+		}
+		mentions[id] = append(mentions[id], mention)
+	}
+
+	return mentions, rows.Err()
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMentionIgnoresCase(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	users := createTestUsers(t, db, "author", "mentioned")
+	author, mentioned := users[0], users[1]
+	if _, err := db.Exec(`UPDATE users SET is_active = TRUE WHERE id = $1`, mentioned.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	username := strings.ToUpper(mentioned.Username)
+	post := &Post{Title: "mention", Content: "@" + username, Format: ContentFormatPlain, UserID: author.ID,
+		Tags: []string{}, Status: PostStatusPublished, Visibility: PostVisibilityPublic,
+		Mentions: []Mention{{Username: username, Start: 0, End: len(username) + 1}}}
+	if err := s.Posts.Create(ctx, post); err != nil {
+		t.Fatal(err)
+	}
+	if len(post.Mentions) != 1 || post.Mentions[0].UserID != mentioned.ID {
+		t.Errorf("Expected @%s to mention user %d, but got %+v", username, mentioned.ID, post.Mentions)
+	}
+}
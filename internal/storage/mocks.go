@@ -2,12 +2,31 @@ package storage
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"time"
 )
 
 func NewMockStorage() Storage {
 	return Storage{
-		Users: &UserMockStorage{},
+		Posts:       &PostMockStorage{},
+		Users:       &UserMockStorage{},
+		Comments:    &CommentMockStorage{},
+		Roles:       &RoleMockStorage{},
+		Reactions:   &ReactionMockStorage{},
+		Revisions:   &RevisionMockStorage{},
+		Attachments: &AttachmentMockStorage{count: make(map[int64]int)},
+		Mentions:    &MentionMockStorage{},
+		Tags:        &TagMockStorage{},
+		Bookmarks:   &BookmarkMockStorage{},
+		Reposts:     &RepostMockStorage{},
+		Polls:       &PollMockStorage{},
+		Pins:        &PinMockStorage{},
+
+		LinkPreviews: &LinkPreviewMockStorage{},
+		Sessions:     &SessionMockStorage{used: make(map[string]bool), revoked: make(map[int64]bool)},
+		TwoFactor:    &TwoFactorMockStorage{attempts: make(map[int64]int), challenges: make(map[string]int64)},
+		Identities:   &IdentityMockStorage{states: make(map[string]OAuthState)},
 	}
 }
 
@@ -31,8 +50,18 @@ func (u *UserMockStorage) GetByID(ctx context.Context, userId int64) (*User, err
 	return &User{}, nil
 }
 
-func (u *UserMockStorage) GetByEmail(ctx context.Context, email string) (*User, error) {
+func (u *UserMockStorage) UpdateProfile(ctx context.Context, user *User, cooldown time.Duration) error {
+	return nil
+}
 
+// MockInactiveEmail is the email of a user who has not activated yet, so UserMockStorage does not find it and
+// IdentityMockStorage cannot create a user with it.
+const MockInactiveEmail = "inactive@example.com"
+
+func (u *UserMockStorage) GetByEmail(ctx context.Context, email string) (*User, error) {
+	if email == MockInactiveEmail {
+		return nil, ErrNotFound
+	}
 	return &User{}, nil
 }
 
@@ -48,7 +77,453 @@ func (u *UserMockStorage) UnFollow(ctx context.Context, fId int64, userId int64)
 
 }
 
+func (u *UserMockStorage) GetStats(ctx context.Context, userID, viewerID int64) (*UserStats, error) {
+
+	return &UserStats{}, nil
+}
+
+func (u *UserMockStorage) GetFollowers(ctx context.Context, userID, viewerID int64, fq FollowQuery) (*FollowPage, error) {
+
+	return &FollowPage{Users: []FollowEntry{}}, nil
+}
+
+func (u *UserMockStorage) GetFollowing(ctx context.Context, userID, viewerID int64, fq FollowQuery) (*FollowPage, error) {
+
+	return &FollowPage{Users: []FollowEntry{}}, nil
+}
+
 func (u *UserMockStorage) GetUserFeed(ctx context.Context, user_id int64, pagQ PaginateQuery) ([]PostForFeed, error) {
 
 	return nil, nil
 }
+
+type PostMockStorage struct {
+}
+
+func (p *PostMockStorage) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+
+func (p *PostMockStorage) GetByID(ctx context.Context, postID int64, viewerID int64) (*Post, error) {
+
+	return &Post{ID: postID, Status: PostStatusPublished, Visibility: PostVisibilityPublic}, nil
+}
+
+func (p *PostMockStorage) GetDeletedByID(ctx context.Context, postID int64) (*Post, error) {
+
+	return &Post{ID: postID}, nil
+}
+
+func (p *PostMockStorage) Delete(ctx context.Context, postID int64) error {
+	return nil
+}
+
+func (p *PostMockStorage) Restore(ctx context.Context, postID int64) error {
+	return nil
+}
+
+func (p *PostMockStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (p *PostMockStorage) PublishScheduled(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (p *PostMockStorage) Update(ctx context.Context, post *Post) error {
+	return nil
+}
+
+func (p *PostMockStorage) GetByUserID(ctx context.Context, userID, viewerID int64, pagQ PaginateQuery) ([]PostForFeed, error) {
+
+	return []PostForFeed{}, nil
+}
+
+type CommentMockStorage struct {
+}
+
+func (c *CommentMockStorage) Create(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (c *CommentMockStorage) GetByID(ctx context.Context, commentID int64) (*Comment, error) {
+
+	return &Comment{ID: commentID, PostID: 1}, nil
+}
+
+func (c *CommentMockStorage) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+
+	return []Comment{}, nil
+}
+
+func (c *CommentMockStorage) GetPageByPostID(ctx context.Context, postID int64, cq CommentQuery) (*CommentPage, error) {
+
+	return &CommentPage{Comments: []Comment{}}, nil
+}
+
+func (c *CommentMockStorage) Update(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+func (c *CommentMockStorage) Delete(ctx context.Context, commentID int64) error {
+	return nil
+}
+
+type RoleMockStorage struct {
+}
+
+// mockRoleIDs are the IDs the roles migration gives the roles, higher IDs grant more.
+var mockRoleIDs = map[string]int64{"preview user": 1, "user": 2, "moderator": 3, "admin": 4}
+
+func (r *RoleMockStorage) GetByName(ctx context.Context, name string) (*Role, error) {
+	id, ok := mockRoleIDs[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &Role{ID: id, Name: name}, nil
+}
+
+type ReactionMockStorage struct {
+}
+
+func (r *ReactionMockStorage) Add(ctx context.Context, postID, userID int64, kind string) error {
+	return nil
+}
+
+func (r *ReactionMockStorage) Remove(ctx context.Context, postID, userID int64, kind string) error {
+	return nil
+}
+
+func (r *ReactionMockStorage) GetByPostID(ctx context.Context, postID, userID int64) (ReactionSummary, error) {
+
+	return newReactionSummary(), nil
+}
+
+type RevisionMockStorage struct {
+}
+
+func (r *RevisionMockStorage) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+
+	return []PostRevision{}, nil
+}
+
+func (r *RevisionMockStorage) GetByVersion(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	if version != 1 {
+		return nil, ErrNotFound
+	}
+
+	return &PostRevision{PostID: postID, Version: version, Title: "old title", Content: "old content"}, nil
+}
+
+// AttachmentMockStorage counts the attachments created per post, so uploads run into the limit.
+type AttachmentMockStorage struct {
+	mu    sync.Mutex
+	count map[int64]int
+}
+
+func (a *AttachmentMockStorage) Create(ctx context.Context, attachments []Attachment, max int) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	postID := attachments[0].PostID
+	if a.count[postID]+len(attachments) > max {
+		return ErrAttachmentLimit
+	}
+	a.count[postID] += len(attachments)
+	return nil
+}
+
+// GetByKey finds the blobs of post 1, the blobs of other posts are not visible.
+func (a *AttachmentMockStorage) GetByKey(ctx context.Context, key string, viewerID int64) (*Attachment, error) {
+	if !strings.HasPrefix(key, "posts/1/") {
+		return nil, ErrNotFound
+	}
+
+	return &Attachment{ID: 1, PostID: 1, Key: key}, nil
+}
+
+func (a *AttachmentMockStorage) GetByID(ctx context.Context, attachmentID int64) (*Attachment, error) {
+
+	return &Attachment{ID: attachmentID, PostID: 1}, nil
+}
+
+func (a *AttachmentMockStorage) GetByPostID(ctx context.Context, postID int64) ([]Attachment, error) {
+
+	return []Attachment{}, nil
+}
+
+func (a *AttachmentMockStorage) Delete(ctx context.Context, attachmentID int64) error {
+	return nil
+}
+
+func (a *AttachmentMockStorage) GetKeysDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+
+	return []string{}, nil
+}
+
+type MentionMockStorage struct {
+}
+
+func (m *MentionMockStorage) GetByUserID(ctx context.Context, userID int64, pagQ PaginateQuery) ([]MentionItem, error) {
+
+	return []MentionItem{}, nil
+}
+
+type TagMockStorage struct {
+}
+
+func (t *TagMockStorage) GetPosts(ctx context.Context, tag string, pagQ PaginateQuery) ([]PostForFeed, error) {
+
+	return []PostForFeed{}, nil
+}
+
+func (t *TagMockStorage) GetTrending(ctx context.Context, since time.Time, limit int) ([]TrendingTag, error) {
+
+	return []TrendingTag{}, nil
+}
+
+type BookmarkMockStorage struct {
+}
+
+func (b *BookmarkMockStorage) Add(ctx context.Context, postID, userID int64) error {
+	return nil
+}
+
+func (b *BookmarkMockStorage) Remove(ctx context.Context, postID, userID int64) error {
+	return nil
+}
+
+func (b *BookmarkMockStorage) GetByUserID(ctx context.Context, userID int64, pagQ PaginateQuery) ([]PostForFeed, error) {
+
+	return []PostForFeed{}, nil
+}
+
+type RepostMockStorage struct {
+}
+
+func (r *RepostMockStorage) Add(ctx context.Context, postID, userID int64) error {
+	return nil
+}
+
+func (r *RepostMockStorage) Remove(ctx context.Context, postID, userID int64) error {
+	return nil
+}
+
+type PollMockStorage struct {
+}
+
+func (p *PollMockStorage) GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	votes := 1
+	return &Poll{
+		ID:             1,
+		PostID:         postID,
+		Options:        []PollOption{{ID: 1, Text: "yes", Votes: &votes}, {ID: 2, Text: "no", Votes: new(int)}},
+		ViewerChoices:  []int64{},
+		TotalVoters:    &votes,
+		ResultsVisible: true,
+	}, nil
+}
+
+func (p *PollMockStorage) Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error {
+	return nil
+}
+
+type PinMockStorage struct {
+}
+
+func (p *PinMockStorage) Pin(ctx context.Context, postID, userID int64, max int) error {
+	return nil
+}
+
+func (p *PinMockStorage) Unpin(ctx context.Context, postID, userID int64) error {
+	return nil
+}
+
+// Reorder accepts the orders of the pinned posts 1 and 2.
+func (p *PinMockStorage) Reorder(ctx context.Context, userID int64, postIDs []int64) error {
+	if len(postIDs) != 2 || postIDs[0]+postIDs[1] != 3 || postIDs[0] == postIDs[1] {
+		return ErrPinOrder
+	}
+	return nil
+}
+
+type LinkPreviewMockStorage struct {
+}
+
+func (l *LinkPreviewMockStorage) GetByURL(ctx context.Context, url string, fetchedAfter time.Time) (*LinkPreview, error) {
+
+	return nil, ErrNotFound
+}
+
+func (l *LinkPreviewMockStorage) Save(ctx context.Context, preview *LinkPreview) error {
+	return nil
+}
+
+func (l *LinkPreviewMockStorage) Attach(ctx context.Context, postID int64, url string) error {
+	return nil
+}
+
+// SessionMockStorage has the sessions 1 and 2 of the test user. It remembers the used refresh tokens, so
+// presenting one again fails like a reuse, and the revoked sessions, so their access tokens are refused.
+type SessionMockStorage struct {
+	mu      sync.Mutex
+	used    map[string]bool
+	revoked map[int64]bool
+}
+
+func (s *SessionMockStorage) Create(ctx context.Context, session *Session, tokenHash string, exp time.Duration) error {
+	session.ID = 1
+	return nil
+}
+
+func (s *SessionMockStorage) Rotate(ctx context.Context, tokenHash, nextHash, ip string, exp time.Duration) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used[tokenHash] {
+		return nil, ErrTokenReused
+	}
+	s.used[tokenHash] = true
+	return &Session{ID: 1, UserID: 1}, nil
+}
+
+func (s *SessionMockStorage) RevokeByToken(ctx context.Context, tokenHash string) error {
+	return nil
+}
+
+func (s *SessionMockStorage) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+
+	return []Session{{ID: 1, UserID: userID}, {ID: 2, UserID: userID}}, nil
+}
+
+func (s *SessionMockStorage) Touch(ctx context.Context, sessionID, userID int64, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revoked[sessionID] {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SessionMockStorage) Revoke(ctx context.Context, sessionID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sessionID != 1 && sessionID != 2 || s.revoked[sessionID] {
+		return ErrNotFound
+	}
+	s.revoked[sessionID] = true
+	return nil
+}
+
+// MockTOTPSecret is the secret of the two factor enrollment returned by TwoFactorMockStorage.
+const MockTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// TwoFactorMockStorage keeps the attempts and the login challenges in memory, so they can be used up.
+type TwoFactorMockStorage struct {
+	mu         sync.Mutex
+	attempts   map[int64]int
+	challenges map[string]int64
+}
+
+func (s *TwoFactorMockStorage) GetByUserID(ctx context.Context, userID int64) (*TwoFactor, error) {
+
+	return &TwoFactor{UserID: userID, Secret: MockTOTPSecret}, nil
+}
+
+func (s *TwoFactorMockStorage) Enroll(ctx context.Context, userID int64, secret string) error {
+	return nil
+}
+
+func (s *TwoFactorMockStorage) Confirm(ctx context.Context, userID, step int64, codeHashes []string) error {
+	return nil
+}
+
+func (s *TwoFactorMockStorage) UseStep(ctx context.Context, userID, step int64) error {
+	return nil
+}
+
+func (s *TwoFactorMockStorage) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	return nil
+}
+
+func (s *TwoFactorMockStorage) CountAttempt(ctx context.Context, userID int64, max int, lockout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attempts[userID] >= max {
+		return ErrLocked
+	}
+	s.attempts[userID]++
+	return nil
+}
+
+func (s *TwoFactorMockStorage) ResetAttempts(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, userID)
+	return nil
+}
+
+func (s *TwoFactorMockStorage) CreateChallenge(ctx context.Context, challengeID string, userID int64, exp time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[challengeID] = userID
+	return nil
+}
+
+func (s *TwoFactorMockStorage) UseChallenge(ctx context.Context, challengeID string, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.challenges[challengeID]; !ok || id != userID {
+		return ErrNotFound
+	}
+	delete(s.challenges, challengeID)
+	return nil
+}
+
+func (s *TwoFactorMockStorage) Disable(ctx context.Context, userID int64) error {
+	return nil
+}
+
+// IdentityMockStorage links no identities yet and keeps the login states in memory, so a login can complete.
+type IdentityMockStorage struct {
+	mu     sync.Mutex
+	states map[string]OAuthState
+}
+
+func (s *IdentityMockStorage) GetUser(ctx context.Context, provider, subject string) (*User, error) {
+
+	return nil, ErrNotFound
+}
+
+func (s *IdentityMockStorage) Link(ctx context.Context, userID int64, provider, subject, email string) error {
+	return nil
+}
+
+func (s *IdentityMockStorage) CreateUser(ctx context.Context, user *User, provider, subject string) error {
+	if user.Email == MockInactiveEmail {
+		return ErrEmailTaken
+	}
+	user.ID = 1
+	user.Is_Active = true
+	return nil
+}
+
+func (s *IdentityMockStorage) CreateState(ctx context.Context, state *OAuthState, exp time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[state.StateHash] = *state
+	return nil
+}
+
+func (s *IdentityMockStorage) ConsumeState(ctx context.Context, stateHash string) (*OAuthState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[stateHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.states, stateHash)
+	return &state, nil
+}
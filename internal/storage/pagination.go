@@ -53,3 +53,62 @@ func (pq PaginateQuery) Parse(r *http.Request) (PaginateQuery, error) {
 	return pq, nil
 
 }
+
+const (
+	CommentSortNewest      = "newest"
+	CommentSortOldest      = "oldest"
+	CommentSortMostReplied = "most_replied"
+)
+
+type CommentQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Sort   string `json:"sort" validate:"oneof=newest oldest most_replied"`
+	Cursor string `json:"cursor" validate:"max=200"`
+}
+
+func (cq CommentQuery) Parse(r *http.Request) (CommentQuery, error) {
+	queryS := r.URL.Query()
+	limit := queryS.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return cq, err
+		}
+		cq.Limit = l
+	}
+
+	sort := queryS.Get("sort")
+	if sort != "" {
+		cq.Sort = sort
+	}
+
+	cursor := queryS.Get("cursor")
+	if cursor != "" {
+		cq.Cursor = cursor
+	}
+	return cq, nil
+}
+
+// FollowQuery pages through follower lists, newest follow first.
+type FollowQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor" validate:"max=200"`
+}
+
+func (fq FollowQuery) Parse(r *http.Request) (FollowQuery, error) {
+	queryS := r.URL.Query()
+	limit := queryS.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return fq, err
+		}
+		fq.Limit = l
+	}
+
+	cursor := queryS.Get("cursor")
+	if cursor != "" {
+		fq.Cursor = cursor
+	}
+	return fq, nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PinStorage struct {
+	db *sql.DB
+}
+
+// Pin pins the post to the top of its author's posts after the already pinned ones. Pinning a pinned post has no
+// effect, pinning beyond max posts fails with ErrPinLimit.
+func (p *PinStorage) Pin(ctx context.Context, postID, userID int64, max int) error {
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// Locking the user serializes the pins of a user, so concurrent requests cannot overshoot the limit.
+		query := `SELECT id FROM users WHERE id = $1 FOR UPDATE;`
+		var id int64
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&id); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		var pinned int
+		var alreadyPinned bool
+		query = `SELECT COUNT(*), COALESCE(BOOL_OR(post_id = $2), FALSE) FROM pinned_posts WHERE user_id = $1;`
+		if err := tx.QueryRowContext(ctx, query, userID, postID).Scan(&pinned, &alreadyPinned); err != nil {
+			return err
+		}
+		if alreadyPinned {
+			return nil
+		}
+		if pinned >= max {
+			return ErrPinLimit
+		}
+
+		query = `INSERT INTO pinned_posts (user_id, post_id, position)
+			SELECT $1, $2, COALESCE(MAX(position), 0) + 1 FROM pinned_posts WHERE user_id = $1;`
+		if _, err := tx.ExecContext(ctx, query, userID, postID); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrNotFound
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+func (p *PinStorage) Unpin(ctx context.Context, postID, userID int64) error {
+	query := `DELETE FROM pinned_posts WHERE user_id = $1 AND post_id = $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := p.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reorder arranges the pinned posts of the user in the given order, which has to list every pinned post exactly
+// once or the call fails with ErrPinOrder.
+func (p *PinStorage) Reorder(ctx context.Context, userID int64, postIDs []int64) error {
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `SELECT post_id FROM pinned_posts WHERE user_id = $1 FOR UPDATE;`
+		rows, err := tx.QueryContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+		pinned := map[int64]bool{}
+		for rows.Next() {
+			var postID int64
+			if err := rows.Scan(&postID); err != nil {
+				rows.Close()
+				return err
+			}
+			pinned[postID] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(postIDs) != len(pinned) {
+			return ErrPinOrder
+		}
+		for _, postID := range postIDs {
+			if !pinned[postID] {
+				return ErrPinOrder
+			}
+			delete(pinned, postID)
+		}
+
+		query = `UPDATE pinned_posts pp SET position = o.position
+			FROM unnest($2::bigint[]) WITH ORDINALITY AS o(post_id, position)
+			WHERE pp.user_id = $1 AND pp.post_id = o.post_id;`
+		_, err = tx.ExecContext(ctx, query, userID, pq.Array(postIDs))
+		return err
+	})
+}
+
+// deletePins unpins the post wherever it is pinned.
+func deletePins(ctx context.Context, tx *sql.Tx, postID int64) error {
+	query := `DELETE FROM pinned_posts WHERE post_id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, postID)
+	return err
+}
+
+// deletePinsOnUpdate unpins the updated post when its stored visibility differs from the new one, or when the
+// update unpublishes it by moving it back to draft or scheduling it for later.
+func deletePinsOnUpdate(ctx context.Context, tx *sql.Tx, post *Post, now time.Time) error {
+	query := `DELETE FROM pinned_posts pp USING posts p
+		WHERE pp.post_id = p.id AND p.id = $1 AND (p.visibility <> $2 OR NOT $3);`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, post.ID, post.Visibility, post.Published(now))
+	return err
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUnpinOnUpdate(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	owner := createTestUsers(t, db, "pinner")[0]
+
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name   string
+		update func(*Post)
+		pinned bool
+	}{
+		{"edit", func(p *Post) { p.Content = "edited" }, true},
+		{"draft", func(p *Post) { p.Status = PostStatusDraft }, false},
+		{"reschedule", func(p *Post) { p.Status = PostStatusScheduled; p.PublishAt = &future }, false},
+		{"visibility", func(p *Post) { p.Visibility = PostVisibilityFollowers }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := &Post{Title: tt.name, Content: tt.name, Format: ContentFormatPlain, UserID: owner.ID, Tags: []string{},
+				Status: PostStatusPublished, Visibility: PostVisibilityPublic}
+			if err := s.Posts.Create(ctx, post); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Pins.Pin(ctx, post.ID, owner.ID, 10); err != nil {
+				t.Fatal(err)
+			}
+			tt.update(post)
+			if err := s.Posts.Update(ctx, post); err != nil {
+				t.Fatal(err)
+			}
+
+			var pinned bool
+			err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pinned_posts WHERE post_id = $1)`, post.ID).Scan(&pinned)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pinned != tt.pinned {
+				t.Errorf("expected pinned to be %v after the update", tt.pinned)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Poll is attached to a post. TotalVoters and the option vote counts are nil while the results are hidden from
+// the viewer.
+type Poll struct {
+	ID             int64        `json:"id"`
+	PostID         int64        `json:"post_id"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	ClosesAt       *time.Time   `json:"closes_at"`
+	Closed         bool         `json:"closed"`
+	Options        []PollOption `json:"options"`
+	Voted          bool         `json:"voted"`
+	ViewerChoices  []int64      `json:"viewer_choices"`
+	TotalVoters    *int         `json:"total_voters"`
+	ResultsVisible bool         `json:"results_visible"`
+}
+
+type PollOption struct {
+	ID    int64  `json:"id"`
+	Text  string `json:"text"`
+	Votes *int   `json:"votes"`
+}
+
+// HideResults drops the vote counts, the viewer's own choices stay visible.
+func (p *Poll) HideResults() {
+	p.TotalVoters = nil
+	for i := range p.Options {
+		p.Options[i].Votes = nil
+	}
+	p.ResultsVisible = false
+}
+
+type PollStorage struct {
+	db *sql.DB
+}
+
+// GetByPostID loads the poll of a post with its results and the choices of the viewer.
+func (p *PollStorage) GetByPostID(ctx context.Context, postID, viewerID int64) (*Poll, error) {
+	poll := &Poll{PostID: postID, Options: []PollOption{}, ViewerChoices: []int64{}, ResultsVisible: true}
+	query := `SELECT id, multiple_choice, closes_at, closes_at IS NOT NULL AND closes_at <= NOW(),
+			(SELECT COUNT(*) FROM poll_votes v WHERE v.poll_id = polls.id)
+		FROM polls WHERE post_id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	var totalVoters int
+	err := p.db.QueryRowContext(ctx, query, postID).Scan(
+		&poll.ID,
+		&poll.MultipleChoice,
+		&poll.ClosesAt,
+		&poll.Closed,
+		&totalVoters)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	poll.TotalVoters = &totalVoters
+
+	query = `SELECT o.id, o.text, COUNT(c.option_id), BOOL_OR(c.user_id = $2)
+		FROM poll_options o
+		LEFT JOIN poll_vote_choices c ON c.poll_id = o.poll_id AND c.option_id = o.id
+		WHERE o.poll_id = $1
+		GROUP BY o.id
+		ORDER BY o.position;`
+	rows, err := p.db.QueryContext(ctx, query, poll.ID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var option PollOption
+		var votes int
+		var mine sql.NullBool
+		if err := rows.Scan(&option.ID, &option.Text, &votes, &mine); err != nil {
+			return nil, err
+		}
+		option.Votes = &votes
+		if mine.Bool {
+			poll.Voted = true
+			poll.ViewerChoices = append(poll.ViewerChoices, option.ID)
+		}
+		poll.Options = append(poll.Options, option)
+	}
+
+	return poll, rows.Err()
+}
+
+// Vote records the user's choices, a user votes once per poll and cannot change the vote afterwards.
+func (p *PollStorage) Vote(ctx context.Context, pollID, userID int64, optionIDs []int64) error {
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO poll_votes (poll_id, user_id)
+			SELECT id, $2 FROM polls WHERE id = $1 AND (closes_at IS NULL OR closes_at > NOW());`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, pollID, userID)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrConflict
+			}
+			return err
+		}
+		rowsCount, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsCount == 0 {
+			return ErrPollClosed
+		}
+
+		query = `INSERT INTO poll_vote_choices (poll_id, user_id, option_id) SELECT $1, $2, unnest($3::bigint[]);`
+		if _, err := tx.ExecContext(ctx, query, pollID, userID, pq.Array(optionIDs)); err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+				return ErrNotFound
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// createPoll saves the poll of a new post with its options in their given order.
+func createPoll(ctx context.Context, tx *sql.Tx, poll *Poll) error {
+	query := `INSERT INTO polls (post_id, multiple_choice, closes_at) VALUES ($1, $2, $3) RETURNING id;`
+	if err := tx.QueryRowContext(ctx, query, poll.PostID, poll.MultipleChoice, poll.ClosesAt).Scan(&poll.ID); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO poll_options (poll_id, position, text) VALUES ($1, $2, $3) RETURNING id;`
+	for i := range poll.Options {
+		if err := tx.QueryRowContext(ctx, query, poll.ID, i, poll.Options[i].Text).Scan(&poll.Options[i].ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -12,6 +13,7 @@ type Post struct {
 	ID        int64     `json:"id"`
 	Title     string    `json:"title"`
 	Content   string    `json:"content"`
+	Format    string    `json:"format"`
 	UserID    int64     `json:"user_id"`
 	Tags      []string  `json:"tags"`
 	Version   int       `json:"version"`
@@ -19,41 +21,136 @@ type Post struct {
 	UpdatedAt string    `json:"updated_at"`
 	Comments  []Comment `json:"comment"`
 	User      User      `json:"user"`
+
+	Status     string     `json:"status"`
+	PublishAt  *time.Time `json:"publish_at"`
+	Visibility string     `json:"visibility"`
+
+	QuotedPostID *int64 `json:"quoted_post_id"`
+	QuotedPost   *Post  `json:"quoted_post,omitempty"`
+	Poll         *Poll  `json:"poll,omitempty"`
+
+	// LinkPreview is filled in asynchronously after the post is saved, until then the post has none.
+	LinkPreview *LinkPreview `json:"link_preview,omitempty"`
+
+	// ContentHTML is Content rendered for display, it is filled in by the API and never stored.
+	ContentHTML string `json:"content_html"`
+
+	CommentCount       int             `json:"comment_count"`
+	CommentsNextCursor string          `json:"comments_next_cursor,omitempty"`
+	Reactions          ReactionSummary `json:"reactions"`
+	Attachments        []Attachment    `json:"attachments"`
+	Mentions           []Mention       `json:"mentions"`
+}
+
+// Content formats of posts and comments.
+const (
+	ContentFormatPlain    = "plain"
+	ContentFormatMarkdown = "markdown"
+)
+
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
+// publishedFilter matches the posts everyone can read, scheduled posts count as soon as their time has come
+// even if the publisher has not flipped them yet.
+const publishedFilter = `(p.status = 'published' OR (p.status = 'scheduled' AND p.publish_at <= NOW()))`
+
+// Published is publishedFilter for a loaded post.
+func (p *Post) Published(now time.Time) bool {
+	return p.Status == PostStatusPublished ||
+		(p.Status == PostStatusScheduled && p.PublishAt != nil && !p.PublishAt.After(now))
+}
+
+const (
+	PostVisibilityPublic    = "public"
+	PostVisibilityFollowers = "followers"
+	PostVisibilityPrivate   = "private"
+)
+
+// publicFilter matches the posts anyone can read, even without logging in.
+const publicFilter = `(` + publishedFilter + ` AND p.visibility = 'public')`
+
+// visibleTo matches the posts the viewer, given as a query placeholder, can read: their own posts and the
+// published ones that are public or shared with followers of the author they follow.
+func visibleTo(viewer string) string {
+	return `(p.user_id = ` + viewer + ` OR (` + publishedFilter + ` AND (p.visibility = 'public' OR
+		(p.visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM followers vf WHERE vf.user_id = p.user_id AND vf.follower_id = ` + viewer + `)))))`
 }
 
 type PostForFeed struct {
 	Post         Post
-	CommentCount int `json:"comment_count"`
+	CommentCount int             `json:"comment_count"`
+	Reactions    ReactionSummary `json:"reactions"`
+	Bookmarked   bool            `json:"bookmarked"`
+	RepostCount  int             `json:"repost_count"`
+	QuoteCount   int             `json:"quote_count"`
+	Pinned       bool            `json:"pinned"`
+	// RepostedBy is set when the post is in the feed because a followed user reposted it.
+	RepostedBy *User `json:"reposted_by,omitempty"`
 }
 
 type PostStorage struct {
 	db *sql.DB
 }
 
+// Create saves the post together with its poll and the mentions in its content, see saveMentions.
 func (p *PostStorage) Create(ctx context.Context, post *Post) error {
-	query := `INSERT INTO posts (title, content, user_id, tags) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at;`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO posts (title, content, format, user_id, tags, status, publish_at, visibility, quoted_post_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at, updated_at;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	err := p.db.QueryRowContext(ctx, query, post.Title, post.Content, post.UserID,
-		pq.Array(post.Tags)).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
-	if err != nil {
+		err := tx.QueryRowContext(ctx, query, post.Title, post.Content, post.Format, post.UserID,
+			pq.Array(post.Tags), post.Status, post.PublishAt, post.Visibility, post.QuotedPostID).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		if post.Poll != nil {
+			post.Poll.PostID = post.ID
+			if err := createPoll(ctx, tx, post.Poll); err != nil {
+				return err
+			}
+		}
+
+		post.Mentions, err = saveMentions(ctx, tx, post.ID, nil, post.Mentions)
 		return err
-	}
-	return nil
+	})
 }
 
-func (p *PostStorage) GetByID(ctx context.Context, postID int64) (*Post, error) {
+// GetByID fetches a post the viewer can read, see visibleTo. Posts the viewer cannot see are not found.
+func (p *PostStorage) GetByID(ctx context.Context, postID int64, viewerID int64) (*Post, error) {
+	return p.getByID(ctx, postID, "p.deleted_at IS NULL AND "+visibleTo("$2"), viewerID)
+}
+
+// GetDeletedByID fetches a post that is in the trash, waiting to be restored or purged.
+func (p *PostStorage) GetDeletedByID(ctx context.Context, postID int64) (*Post, error) {
+	return p.getByID(ctx, postID, "p.deleted_at IS NOT NULL")
+}
+
+func (p *PostStorage) getByID(ctx context.Context, postID int64, filter string, args ...any) (*Post, error) {
 	var post Post
-	query := `SELECT id, title, user_id, content,  tags, created_at, updated_at FROM posts WHERE id = $1;`
+	query := `SELECT p.id, p.title, p.user_id, p.content, p.format, p.tags, p.version, p.status, p.publish_at, p.visibility, p.quoted_post_id, p.created_at, p.updated_at
+		FROM posts p WHERE p.id = $1 AND ` + filter + `;`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := p.db.QueryRowContext(ctx, query, postID).Scan(
+	err := p.db.QueryRowContext(ctx, query, append([]any{postID}, args...)...).Scan(
 		&post.ID,
 		&post.Title,
 		&post.UserID,
 		&post.Content,
+		&post.Format,
 		pq.Array(&post.Tags),
+		&post.Version,
+		&post.Status,
+		&post.PublishAt,
+		&post.Visibility,
+		&post.QuotedPostID,
 		&post.CreatedAt,
 		&post.UpdatedAt)
 	if err != nil {
@@ -66,50 +163,235 @@ func (p *PostStorage) GetByID(ctx context.Context, postID int64) (*Post, error)
 
 	}
 
+	mentions, err := getPostMentions(ctx, p.db, []int64{post.ID})
+	if err != nil {
+		return nil, err
+	}
+	post.Mentions = mentions[post.ID]
+	previews, err := getLinkPreviews(ctx, p.db, []int64{post.ID})
+	if err != nil {
+		return nil, err
+	}
+	post.LinkPreview = previews[post.ID]
+
 	return &post, nil
 }
 
+// Delete moves the post to the trash, it is removed for good by Purge once the retention window passes.
+// The post is unpinned, restoring it does not pin it again.
 func (p *PostStorage) Delete(ctx context.Context, postId int64) error {
-	query := `DELETE FROM posts WHERE id=$1;`
-	//ToDo : delete comments
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		if err := deletePins(ctx, tx, postId); err != nil {
+			return err
+		}
+		query := `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL;`
+		return p.execOne(ctx, tx, query, postId)
+	})
+}
+
+func (p *PostStorage) Restore(ctx context.Context, postId int64) error {
+	query := `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;`
+	return p.execOne(ctx, p.db, query, postId)
+}
+
+// Purge permanently removes the posts deleted before the given time together with their comments.
+func (p *PostStorage) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := withTx(p.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `DELETE FROM comments WHERE post_id IN (SELECT id FROM posts WHERE deleted_at < $1);`
+		if _, err := tx.ExecContext(ctx, query, deletedBefore); err != nil {
+			return err
+		}
+
+		query = `DELETE FROM posts WHERE deleted_at < $1;`
+		res, err := tx.ExecContext(ctx, query, deletedBefore)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (p *PostStorage) execOne(ctx context.Context, db execer, query string, postId int64) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	res, err := p.db.ExecContext(ctx, query, postId)
+	res, err := db.ExecContext(ctx, query, postId)
 	if err != nil {
 		return err
 	}
 	rowsCount, err := res.RowsAffected()
 	if err != nil {
-
-		if rowsCount == 0 {
-			return ErrNotFound
-		} else if rowsCount != 1 {
-			return ErrTooMuchChanged
-		}
 		return err
 	}
+	if rowsCount == 0 {
+		return ErrNotFound
+	} else if rowsCount != 1 {
+		return ErrTooMuchChanged
+	}
 	return nil
 
 }
 
+// PublishScheduled publishes the scheduled posts whose publish time is before now.
+func (p *PostStorage) PublishScheduled(ctx context.Context, now time.Time) (int64, error) {
+	query := `UPDATE posts SET status = 'published' WHERE status = 'scheduled' AND publish_at <= $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	res, err := p.db.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Update saves the post and keeps the replaced title and content as a revision of the previous version,
+// the stored mentions are replaced by post.Mentions. Changing the visibility or unpublishing the post unpins it.
 func (p *PostStorage) Update(ctx context.Context, post *Post) error {
-	query := `UPDATE posts
-		SET title = $1, content = $2, version = version + 1
-		WHERE id = $3 AND version=$4 
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		if err := createRevision(ctx, tx, post.ID, post.Version); err != nil {
+			return err
+		}
+		if err := deletePinsOnUpdate(ctx, tx, post, time.Now()); err != nil {
+			return err
+		}
+
+		query := `UPDATE posts
+		SET title = $1, content = $2, format = $3, tags = $4, status = $5, publish_at = $6, visibility = $7,
+			version = version + 1
+		WHERE id = $8 AND version=$9 AND deleted_at IS NULL
 		RETURNING version`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+		err := tx.QueryRowContext(ctx, query, post.Title, post.Content, post.Format, pq.Array(post.Tags), post.Status, post.PublishAt, post.Visibility, post.ID,
+			post.Version).Scan(&post.Version)
+
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		post.Mentions, err = saveMentions(ctx, tx, post.ID, nil, post.Mentions)
+		return err
+	})
+}
+
+// GetByUserID lists the posts of a user the viewer can read, searched and filtered like the feed. The pinned
+// posts come first in their pinned order, the rest follows by creation time.
+func (p *PostStorage) GetByUserID(ctx context.Context, userID, viewerID int64, pagQ PaginateQuery) ([]PostForFeed, error) {
+	query := `
+	SELECT
+		p.id, p.user_id, p.title, p.content, p.format, p.created_at, p.version, p.tags, p.status, p.publish_at, p.visibility, p.quoted_post_id,
+		u.username, COUNT(c.id) AS comments_count, pp.position IS NOT NULL
+	FROM posts p
+	LEFT JOIN comments c ON c.post_id = p.id
+	LEFT JOIN users u ON p.user_id = u.id
+	LEFT JOIN pinned_posts pp ON pp.post_id = p.id AND pp.user_id = p.user_id
+	WHERE p.user_id = $1
+	AND p.deleted_at IS NULL
+	AND ` + visibleTo("$2") + `
+	AND (p.title ILIKE '%' || $5 || '%' OR p.content ILIKE '%' || $5 || '%')
+	AND (p.tags @> $6 OR array_length($6, 1) IS NULL)
+	GROUP BY p.id, u.username, pp.position
+	ORDER BY pp.position IS NULL, pp.position, p.created_at ` + pagQ.Sort + `
+	LIMIT $3 OFFSET $4;`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	err := p.db.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version).Scan(&post.Version)
-
+	rows, err := p.db.QueryContext(ctx, query, userID, viewerID, pagQ.Limit, pagQ.Offset, pagQ.Search, pq.Array(pagQ.Tags))
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrNotFound
-		default:
-			return err
+		return nil, err
+	}
+	defer rows.Close()
+	posts := []PostForFeed{}
+	for rows.Next() {
+		var post PostForFeed
+		if err := rows.Scan(
+			&post.Post.ID,
+			&post.Post.UserID,
+			&post.Post.Title,
+			&post.Post.Content,
+			&post.Post.Format,
+			&post.Post.CreatedAt,
+			&post.Post.Version,
+			pq.Array(&post.Post.Tags),
+			&post.Post.Status,
+			&post.Post.PublishAt,
+			&post.Post.Visibility,
+			&post.Post.QuotedPostID,
+			&post.Post.User.Username,
+			&post.CommentCount,
+			&post.Pinned); err != nil {
+			return nil, err
 		}
+		post.Post.User.ID = post.Post.UserID
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := loadFeedDetails(ctx, p.db, posts, viewerID); err != nil {
+		return nil, err
+	}
 
+	return posts, nil
+}
+
+// loadFeedDetails fills in the reactions, attachments, mentions, bookmark flags, share counts and link previews of
+// the feed items in place.
+func loadFeedDetails(ctx context.Context, db *sql.DB, feed []PostForFeed, viewerID int64) error {
+	postIDs := make([]int64, len(feed))
+	for i := range feed {
+		postIDs[i] = feed[i].Post.ID
+	}
+	reactions, err := getReactionSummaries(ctx, db, postIDs, viewerID)
+	if err != nil {
+		return err
+	}
+	attachments, err := getAttachments(ctx, db, postIDs)
+	if err != nil {
+		return err
+	}
+	mentions, err := getPostMentions(ctx, db, postIDs)
+	if err != nil {
+		return err
+	}
+	bookmarked, err := getBookmarked(ctx, db, postIDs, viewerID)
+	if err != nil {
+		return err
+	}
+	shares, err := getShareCounts(ctx, db, postIDs)
+	if err != nil {
+		return err
+	}
+	previews, err := getLinkPreviews(ctx, db, postIDs)
+	if err != nil {
+		return err
+	}
+	for i := range feed {
+		feed[i].Reactions = reactions[feed[i].Post.ID]
+		feed[i].Post.Attachments = attachments[feed[i].Post.ID]
+		feed[i].Post.Mentions = mentions[feed[i].Post.ID]
+		feed[i].Bookmarked = bookmarked[feed[i].Post.ID]
+		feed[i].RepostCount = shares[feed[i].Post.ID].reposts
+		feed[i].QuoteCount = shares[feed[i].Post.ID].quotes
+		feed[i].Post.LinkPreview = previews[feed[i].Post.ID]
+	}
+	return nil
 }
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// newTestDB connects to the migrated database of TEST_DB_ADDR, the tests that need one are skipped without it.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	addr := os.Getenv("TEST_DB_ADDR")
+	if addr == "" {
+		t.Skip("TEST_DB_ADDR is not set")
+	}
+	db, err := sql.Open("postgres", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createTestUsers creates users with unique names, they are deleted with their posts when the test ends.
+func createTestUsers(t *testing.T, db *sql.DB, names ...string) []*User {
+	t.Helper()
+	ctx := context.Background()
+	s := NewStorage(db)
+	suffix := time.Now().UnixNano() % 1_000_000_000
+	users := []*User{}
+	ids := []int64{}
+	for _, name := range names {
+		user := &User{Username: fmt.Sprintf("%s_%d", name, suffix), Email: fmt.Sprintf("%s_%d@example.com", name, suffix)}
+		if err := user.Password.Set("password"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Users.CreateAndInvite(ctx, user, fmt.Sprintf("token_%s_%d", name, suffix), time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+		ids = append(ids, user.ID)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM posts WHERE user_id = ANY($1)`, pq.Array(ids))
+		for _, id := range ids {
+			s.Users.Delete(ctx, id)
+		}
+	})
+	return users
+}
+
+func TestPostVisibility(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	users := createTestUsers(t, db, "owner", "follower", "stranger")
+	owner, follower, stranger := users[0], users[1], users[2]
+	if err := s.Users.Follow(ctx, owner.ID, follower.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	posts := map[string]*Post{
+		"public":    {Status: PostStatusPublished, Visibility: PostVisibilityPublic},
+		"followers": {Status: PostStatusPublished, Visibility: PostVisibilityFollowers},
+		"private":   {Status: PostStatusPublished, Visibility: PostVisibilityPrivate},
+		"draft":     {Status: PostStatusDraft, Visibility: PostVisibilityPublic},
+		"due":       {Status: PostStatusScheduled, PublishAt: &past, Visibility: PostVisibilityPublic},
+		"scheduled": {Status: PostStatusScheduled, PublishAt: &future, Visibility: PostVisibilityPublic},
+	}
+	for name, post := range posts {
+		post.Title = name
+		post.Content = name
+		post.Format = ContentFormatPlain
+		post.UserID = owner.ID
+		post.Tags = []string{}
+		if err := s.Posts.Create(ctx, post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	visible := map[*User][]string{
+		owner:    {"public", "followers", "private", "draft", "due", "scheduled"},
+		follower: {"public", "followers", "due"},
+		stranger: {"public", "due"},
+	}
+	for viewer, names := range visible {
+		want := make(map[string]bool)
+		for _, name := range names {
+			want[name] = true
+		}
+
+		for name, post := range posts {
+			_, err := s.Posts.GetByID(ctx, post.ID, viewer.ID)
+			switch {
+			case want[name] && err != nil:
+				t.Errorf("%s: expected the %s post, but got %v", viewer.Username, name, err)
+			case !want[name] && !errors.Is(err, ErrNotFound):
+				t.Errorf("%s: expected the %s post to be not found, but got %v", viewer.Username, name, err)
+			}
+		}
+
+		pagQ := PaginateQuery{Limit: 25, Sort: "desc"}
+		userPosts, err := s.Posts.GetByUserID(ctx, owner.ID, viewer.ID, pagQ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPostTitles(t, viewer.Username+" profile", userPosts, want)
+
+		if viewer == stranger {
+			continue
+		}
+		feed, err := s.Users.GetUserFeed(ctx, viewer.ID, pagQ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPostTitles(t, viewer.Username+" feed", feed, want)
+	}
+}
+
+func checkPostTitles(t *testing.T, name string, posts []PostForFeed, want map[string]bool) {
+	t.Helper()
+	got := make(map[string]bool)
+	for _, p := range posts {
+		got[p.Post.Title] = true
+		if !want[p.Post.Title] {
+			t.Errorf("%s: did not expect the %s post", name, p.Post.Title)
+		}
+	}
+	for title := range want {
+		if !got[title] {
+			t.Errorf("%s: expected the %s post", name, title)
+		}
+	}
+}
+
+func TestPostPublished(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	tests := []struct {
+		post Post
+		want bool
+	}{
+		{Post{Status: PostStatusPublished}, true},
+		{Post{Status: PostStatusScheduled, PublishAt: &past}, true},
+		{Post{Status: PostStatusScheduled, PublishAt: &now}, true},
+		{Post{Status: PostStatusScheduled, PublishAt: &future}, false},
+		{Post{Status: PostStatusDraft, PublishAt: &past}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.post.Published(now); got != tt.want {
+			t.Errorf("%s at %v: expected %v, but got %v", tt.post.Status, tt.post.PublishAt, tt.want, got)
+		}
+	}
+}
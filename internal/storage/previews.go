@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LinkPreview is the card of the first link in a post, cached by URL and shared by the posts linking the page.
+type LinkPreview struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	ImageURL    string    `json:"image_url"`
+	SiteName    string    `json:"site_name"`
+	FetchedAt   time.Time `json:"-"`
+}
+
+type LinkPreviewStorage struct {
+	db *sql.DB
+}
+
+// GetByURL returns the cached preview of the URL when it was fetched after fetchedAfter.
+func (l *LinkPreviewStorage) GetByURL(ctx context.Context, url string, fetchedAfter time.Time) (*LinkPreview, error) {
+	query := `SELECT url, title, description, image_url, site_name, fetched_at FROM link_previews
+		WHERE url = $1 AND fetched_at > $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var preview LinkPreview
+	err := l.db.QueryRowContext(ctx, query, url, fetchedAfter).Scan(
+		&preview.URL,
+		&preview.Title,
+		&preview.Description,
+		&preview.ImageURL,
+		&preview.SiteName,
+		&preview.FetchedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &preview, nil
+}
+
+// Save caches the preview, replacing an older one of the same URL.
+func (l *LinkPreviewStorage) Save(ctx context.Context, preview *LinkPreview) error {
+	query := `INSERT INTO link_previews (url, title, description, image_url, site_name) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (url) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url, site_name = EXCLUDED.site_name, fetched_at = NOW()
+		RETURNING fetched_at;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return l.db.QueryRowContext(ctx, query, preview.URL, preview.Title, preview.Description, preview.ImageURL,
+		preview.SiteName).Scan(&preview.FetchedAt)
+}
+
+// Attach shows the cached preview of the URL on the post, an empty URL removes the preview of the post.
+func (l *LinkPreviewStorage) Attach(ctx context.Context, postID int64, url string) error {
+	query := `UPDATE posts SET link_preview_url = NULLIF($2, '') WHERE id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := l.db.ExecContext(ctx, query, postID, url)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// getLinkPreviews loads the previews of the given posts, posts without one are missing from the map.
+func getLinkPreviews(ctx context.Context, db *sql.DB, postIDs []int64) (map[int64]*LinkPreview, error) {
+	query := `SELECT p.id, l.url, l.title, l.description, l.image_url, l.site_name, l.fetched_at
+		FROM posts p JOIN link_previews l ON l.url = p.link_preview_url
+		WHERE p.id = ANY($1);`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	previews := make(map[int64]*LinkPreview, len(postIDs))
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID int64
+		var preview LinkPreview
+		if err := rows.Scan(
+			&postID,
+			&preview.URL,
+			&preview.Title,
+			&preview.Description,
+			&preview.ImageURL,
+			&preview.SiteName,
+			&preview.FetchedAt); err != nil {
+			return nil, err
+		}
+		previews[postID] = &preview
+	}
+
+	return previews, rows.Err()
+}
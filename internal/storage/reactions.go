@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// ReactionKinds lists every reaction a user can leave on a post.
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+type ReactionSummary struct {
+	Counts      map[string]int `json:"counts"`
+	Reacted     bool           `json:"reacted"`
+	ViewerKinds []string       `json:"viewer_kinds"`
+}
+
+type ReactionStorage struct {
+	db *sql.DB
+}
+
+func IsReactionKind(kind string) bool {
+	return slices.Contains(ReactionKinds, kind)
+}
+
+func (r *ReactionStorage) Add(ctx context.Context, postID, userID int64, kind string) error {
+	query := `INSERT INTO post_reactions (post_id, user_id, kind) VALUES ($1, $2, $3)
+		ON CONFLICT (post_id, user_id, kind) DO NOTHING;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, postID, userID, kind)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *ReactionStorage) Remove(ctx context.Context, postID, userID int64, kind string) error {
+	query := `DELETE FROM post_reactions WHERE post_id = $1 AND user_id = $2 AND kind = $3;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, postID, userID, kind)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *ReactionStorage) GetByPostID(ctx context.Context, postID, userID int64) (ReactionSummary, error) {
+	summaries, err := getReactionSummaries(ctx, r.db, []int64{postID}, userID)
+	if err != nil {
+		return ReactionSummary{}, err
+	}
+	return summaries[postID], nil
+}
+
+// getReactionSummaries counts the reactions of the given posts per kind and marks those left by userID.
+// Every requested post gets a summary, even without reactions.
+func getReactionSummaries(ctx context.Context, db *sql.DB, postIDs []int64, userID int64) (map[int64]ReactionSummary, error) {
+	query := `SELECT post_id, kind, COUNT(*), BOOL_OR(user_id = $2)
+		FROM post_reactions
+		WHERE post_id = ANY($1)
+		GROUP BY post_id, kind;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	summaries := make(map[int64]ReactionSummary, len(postIDs))
+	for _, id := range postIDs {
+		summaries[id] = newReactionSummary()
+	}
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(postIDs), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID int64
+		var kind string
+		var count int
+		var mine bool
+		if err := rows.Scan(&postID, &kind, &count, &mine); err != nil {
+			return nil, err
+		}
+		summary := summaries[postID]
+		summary.Counts[kind] = count
+		if mine {
+			summary.Reacted = true
+			summary.ViewerKinds = append(summary.ViewerKinds, kind)
+		}
+		summaries[postID] = summary
+	}
+
+	return summaries, rows.Err()
+}
+
+func newReactionSummary() ReactionSummary {
+	counts := make(map[string]int, len(ReactionKinds))
+	for _, kind := range ReactionKinds {
+		counts[kind] = 0
+	}
+	return ReactionSummary{Counts: counts, ViewerKinds: []string{}}
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+type RepostStorage struct {
+	db *sql.DB
+}
+
+func (r *RepostStorage) Add(ctx context.Context, postID, userID int64) error {
+	query := `INSERT INTO reposts (user_id, post_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, post_id) DO NOTHING;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *RepostStorage) Remove(ctx context.Context, postID, userID int64) error {
+	query := `DELETE FROM reposts WHERE user_id = $1 AND post_id = $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := r.db.ExecContext(ctx, query, userID, postID)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// shareCounts holds how often a post was reposted and quoted by public posts.
+type shareCounts struct {
+	reposts int
+	quotes  int
+}
+
+// getShareCounts counts the reposts and quotes of the given posts, posts without any are missing from the map.
+func getShareCounts(ctx context.Context, db *sql.DB, postIDs []int64) (map[int64]shareCounts, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	counts := make(map[int64]shareCounts, len(postIDs))
+	query := `SELECT post_id, COUNT(*) FROM reposts WHERE post_id = ANY($1) GROUP BY post_id;`
+	if err := scanCounts(ctx, db, query, postIDs, func(id int64, n int) {
+		c := counts[id]
+		c.reposts = n
+		counts[id] = c
+	}); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT p.quoted_post_id, COUNT(*) FROM posts p
+		WHERE p.quoted_post_id = ANY($1) AND p.deleted_at IS NULL AND ` + publicFilter + `
+		GROUP BY p.quoted_post_id;`
+	if err := scanCounts(ctx, db, query, postIDs, func(id int64, n int) {
+		c := counts[id]
+		c.quotes = n
+		counts[id] = c
+	}); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+func scanCounts(ctx context.Context, db *sql.DB, query string, ids []int64, set func(int64, int)) error {
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return err
+		}
+		set(id, n)
+	}
+	return rows.Err()
+}
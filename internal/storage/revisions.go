@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type PostRevision struct {
+	ID        int64  `json:"id"`
+	PostID    int64  `json:"post_id"`
+	Version   int    `json:"version"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+type RevisionStorage struct {
+	db *sql.DB
+}
+
+func (r *RevisionStorage) GetByPostID(ctx context.Context, postID int64) ([]PostRevision, error) {
+	query := `SELECT id, post_id, version, title, content, created_at FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY version DESC;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := []PostRevision{}
+	for rows.Next() {
+		var revision PostRevision
+		if err := rows.Scan(
+			&revision.ID,
+			&revision.PostID,
+			&revision.Version,
+			&revision.Title,
+			&revision.Content,
+			&revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (r *RevisionStorage) GetByVersion(ctx context.Context, postID int64, version int) (*PostRevision, error) {
+	var revision PostRevision
+	query := `SELECT id, post_id, version, title, content, created_at FROM post_revisions
+		WHERE post_id = $1 AND version = $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := r.db.QueryRowContext(ctx, query, postID, version).Scan(
+		&revision.ID,
+		&revision.PostID,
+		&revision.Version,
+		&revision.Title,
+		&revision.Content,
+		&revision.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &revision, nil
+}
+
+// createRevision copies the post as it is at the given version into post_revisions.
+func createRevision(ctx context.Context, tx *sql.Tx, postID int64, version int) error {
+	query := `INSERT INTO post_revisions (post_id, version, title, content)
+		SELECT id, version, title, content FROM posts WHERE id = $1 AND version = $2
+		ON CONFLICT (post_id, version) DO NOTHING;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, postID, version)
+	return err
+}
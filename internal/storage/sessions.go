@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// seenInterval throttles the last seen updates of a session, so most requests only read the session row.
+const seenInterval = "1 minute"
+
+// Session is a login of a user on a device. Its refresh tokens form a family: every refresh uses up the presented
+// token and issues the next one, revoking the session invalidates all of them and its access tokens.
+type Session struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	DeviceLabel string     `json:"device_label"`
+	UserAgent   string     `json:"user_agent"`
+	IP          string     `json:"ip"`
+	CreatedAt   string     `json:"created_at"`
+	LastSeenAt  string     `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	Current     bool       `json:"current"`
+}
+
+type SessionStorage struct {
+	db *sql.DB
+}
+
+// Create starts a session for session.UserID with its first refresh token, given by its hash.
+func (s *SessionStorage) Create(ctx context.Context, session *Session, tokenHash string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO sessions (user_id, device_label, user_agent, ip) VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, last_seen_at;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, session.UserID, session.DeviceLabel, session.UserAgent, session.IP).Scan(
+			&session.ID,
+			&session.CreatedAt,
+			&session.LastSeenAt)
+		if err != nil {
+			return err
+		}
+		return createRefreshToken(ctx, tx, session.ID, tokenHash, exp)
+	})
+}
+
+// Rotate uses up the refresh token and stores its successor in the same session, marking the session as seen from
+// the given address. Unknown, expired and revoked tokens fail with ErrNotFound. Presenting a token that was already
+// used means it leaked, the whole session is revoked and the call fails with ErrTokenReused.
+func (s *SessionStorage) Rotate(ctx context.Context, tokenHash, nextHash, ip string, exp time.Duration) (*Session, error) {
+	var session Session
+	reused := false
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT s.id, s.user_id, s.device_label, s.user_agent, s.created_at, t.used_at IS NOT NULL
+			FROM refresh_tokens t
+			JOIN sessions s ON s.id = t.session_id
+			JOIN users u ON u.id = s.user_id
+			WHERE t.token_hash = $1 AND t.expires_at > NOW() AND s.revoked_at IS NULL AND u.is_active = TRUE
+			FOR UPDATE OF t, s;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var used bool
+		err := tx.QueryRowContext(ctx, query, tokenHash).Scan(
+			&session.ID,
+			&session.UserID,
+			&session.DeviceLabel,
+			&session.UserAgent,
+			&session.CreatedAt,
+			&used)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+		if used {
+			// The revocation has to be committed, so the reuse is reported after the transaction.
+			reused = true
+			return revokeSession(ctx, tx, session.ID)
+		}
+
+		query = `UPDATE refresh_tokens SET used_at = NOW() WHERE token_hash = $1;`
+		if _, err := tx.ExecContext(ctx, query, tokenHash); err != nil {
+			return err
+		}
+		query = `UPDATE sessions SET last_seen_at = NOW(), ip = $2 WHERE id = $1 RETURNING ip, last_seen_at;`
+		if err := tx.QueryRowContext(ctx, query, session.ID, ip).Scan(&session.IP, &session.LastSeenAt); err != nil {
+			return err
+		}
+		return createRefreshToken(ctx, tx, session.ID, nextHash, exp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrTokenReused
+	}
+	return &session, nil
+}
+
+// RevokeByToken ends the session the refresh token belongs to, used tokens of the session included.
+func (s *SessionStorage) RevokeByToken(ctx context.Context, tokenHash string) error {
+	query := `UPDATE sessions SET revoked_at = NOW()
+		WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1) AND revoked_at IS NULL;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, tokenHash)
+	if err != nil {
+		return err
+	}
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetByUserID lists the active sessions of the user, the most recently seen first. A session is active until it is
+// revoked or its last refresh token expires unused.
+func (s *SessionStorage) GetByUserID(ctx context.Context, userID int64) ([]Session, error) {
+	query := `SELECT s.id, s.user_id, s.device_label, s.user_agent, s.ip, s.created_at, s.last_seen_at FROM sessions s
+		WHERE s.user_id = $1 AND s.revoked_at IS NULL AND EXISTS (
+			SELECT 1 FROM refresh_tokens rt
+			WHERE rt.session_id = s.id AND rt.used_at IS NULL AND rt.expires_at > NOW())
+		ORDER BY s.last_seen_at DESC, s.id DESC;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.DeviceLabel,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch checks that the session of the user is still active and records the request as its last activity, at
+// most once per seenInterval. Revoked and unknown sessions fail with ErrNotFound.
+func (s *SessionStorage) Touch(ctx context.Context, sessionID, userID int64, ip string) error {
+	query := `UPDATE sessions SET last_seen_at = NOW(), ip = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND last_seen_at < NOW() - interval '` + seenInterval + `';`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, sessionID, userID, ip)
+	if err != nil {
+		return err
+	}
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount > 0 {
+		return nil
+	}
+
+	query = `SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL);`
+	var active bool
+	if err := s.db.QueryRowContext(ctx, query, sessionID, userID).Scan(&active); err != nil {
+		return err
+	}
+	if !active {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Revoke ends a session of the user.
+func (s *SessionStorage) Revoke(ctx context.Context, sessionID, userID int64) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func createRefreshToken(ctx context.Context, tx *sql.Tx, sessionID int64, tokenHash string, exp time.Duration) error {
+	query := `INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3);`
+	_, err := tx.ExecContext(ctx, query, tokenHash, sessionID, time.Now().Add(exp))
+	return err
+}
+
+func revokeSession(ctx context.Context, tx *sql.Tx, sessionID int64) error {
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL;`
+	_, err := tx.ExecContext(ctx, query, sessionID)
+	return err
+}
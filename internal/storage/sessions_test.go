@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestListActiveSessions(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	user := createTestUsers(t, db, "sessions")[0]
+
+	suffix := time.Now().UnixNano()
+	create := func(label string, exp time.Duration) *Session {
+		session := &Session{UserID: user.ID, DeviceLabel: label}
+		if err := s.Sessions.Create(ctx, session, fmt.Sprintf("%s_%d", label, suffix), exp); err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+	active := create("active", time.Hour)
+	create("expired", -time.Minute)
+	revoked := create("revoked", time.Hour)
+	if err := s.Sessions.Revoke(ctx, revoked.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	rotated := create("rotated", time.Hour)
+	if _, err := s.Sessions.Rotate(ctx, fmt.Sprintf("rotated_%d", suffix), fmt.Sprintf("next_%d", suffix), "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := s.Sessions.GetByUserID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int64]bool{}
+	for _, session := range sessions {
+		got[session.ID] = true
+	}
+	if len(sessions) != 2 || !got[active.ID] || !got[rotated.ID] {
+		t.Errorf("Expected the active and the rotated session, but got %+v", sessions)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	user := createTestUsers(t, db, "reuse")[0]
+
+	suffix := time.Now().UnixNano()
+	first, second, third := fmt.Sprintf("first_%d", suffix), fmt.Sprintf("second_%d", suffix), fmt.Sprintf("third_%d", suffix)
+	session := &Session{UserID: user.ID, DeviceLabel: "laptop"}
+	if err := s.Sessions.Create(ctx, session, first, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sessions.Rotate(ctx, first, second, "", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Sessions.Rotate(ctx, first, third, "", time.Hour); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("Expected ErrTokenReused, but got %v", err)
+	}
+	var revoked bool
+	if err := db.QueryRow(`SELECT revoked_at IS NOT NULL FROM sessions WHERE id = $1`, session.ID).Scan(&revoked); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("Expected the reuse to revoke the session")
+	}
+	if _, err := s.Sessions.Rotate(ctx, second, third, "", time.Hour); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the successor token to be dead, but got %v", err)
+	}
+}
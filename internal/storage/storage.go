@@ -12,14 +12,29 @@ var (
 	ErrTooMuchChanged    = errors.New("the request changed more than expected")
 	QueryTimeoutDuration = time.Second * 5
 	ErrConflict          = errors.New("conflict between resources")
+	ErrInvalidCursor     = errors.New("invalid pagination cursor")
+	ErrPollClosed        = errors.New("the poll is closed")
+	ErrPinLimit          = errors.New("too many pinned posts")
+	ErrPinOrder          = errors.New("the order must list every pinned post once")
+	ErrTokenReused       = errors.New("the refresh token was already used")
+	ErrCodeUsed          = errors.New("the code was already used")
+	ErrUsernameCooldown  = errors.New("the username was changed too recently")
+	ErrLocked            = errors.New("too many failed attempts, try again later")
+	ErrEmailTaken        = errors.New("the email belongs to another user")
+	ErrAttachmentLimit   = errors.New("too many attachments")
 )
 
 type Storage struct {
 	Posts interface {
 		Create(context.Context, *Post) error
-		GetByID(context.Context, int64) (*Post, error)
+		GetByID(context.Context, int64, int64) (*Post, error)
+		GetDeletedByID(context.Context, int64) (*Post, error)
 		Delete(context.Context, int64) error
+		Restore(context.Context, int64) error
+		Purge(context.Context, time.Time) (int64, error)
+		PublishScheduled(context.Context, time.Time) (int64, error)
 		Update(context.Context, *Post) error
+		GetByUserID(context.Context, int64, int64, PaginateQuery) ([]PostForFeed, error)
 	}
 	Users interface {
 		CreateAndInvite(context.Context, *User, string, time.Duration) error
@@ -28,26 +43,124 @@ type Storage struct {
 
 		GetByID(context.Context, int64) (*User, error)
 		GetByEmail(context.Context, string) (*User, error)
+		UpdateProfile(context.Context, *User, time.Duration) error
 
 		Follow(context.Context, int64, int64) error
 		UnFollow(context.Context, int64, int64) error
 
 		GetUserFeed(context.Context, int64, PaginateQuery) ([]PostForFeed, error)
+
+		GetStats(context.Context, int64, int64) (*UserStats, error)
+		GetFollowers(context.Context, int64, int64, FollowQuery) (*FollowPage, error)
+		GetFollowing(context.Context, int64, int64, FollowQuery) (*FollowPage, error)
 	}
 	Comments interface {
+		Create(context.Context, *Comment) error
+		GetByID(context.Context, int64) (*Comment, error)
 		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetPageByPostID(context.Context, int64, CommentQuery) (*CommentPage, error)
+		Update(context.Context, *Comment) error
+		Delete(context.Context, int64) error
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Reactions interface {
+		Add(context.Context, int64, int64, string) error
+		Remove(context.Context, int64, int64, string) error
+		GetByPostID(context.Context, int64, int64) (ReactionSummary, error)
+	}
+	Revisions interface {
+		GetByPostID(context.Context, int64) ([]PostRevision, error)
+		GetByVersion(context.Context, int64, int) (*PostRevision, error)
+	}
+	Attachments interface {
+		Create(context.Context, []Attachment, int) error
+		GetByID(context.Context, int64) (*Attachment, error)
+		GetByKey(context.Context, string, int64) (*Attachment, error)
+		GetByPostID(context.Context, int64) ([]Attachment, error)
+		Delete(context.Context, int64) error
+		GetKeysDeletedBefore(context.Context, time.Time) ([]string, error)
+	}
+	Mentions interface {
+		GetByUserID(context.Context, int64, PaginateQuery) ([]MentionItem, error)
+	}
+	Tags interface {
+		GetPosts(context.Context, string, PaginateQuery) ([]PostForFeed, error)
+		GetTrending(context.Context, time.Time, int) ([]TrendingTag, error)
+	}
+	Bookmarks interface {
+		Add(context.Context, int64, int64) error
+		Remove(context.Context, int64, int64) error
+		GetByUserID(context.Context, int64, PaginateQuery) ([]PostForFeed, error)
+	}
+	Reposts interface {
+		Add(context.Context, int64, int64) error
+		Remove(context.Context, int64, int64) error
+	}
+	Polls interface {
+		GetByPostID(context.Context, int64, int64) (*Poll, error)
+		Vote(context.Context, int64, int64, []int64) error
+	}
+	Pins interface {
+		Pin(context.Context, int64, int64, int) error
+		Unpin(context.Context, int64, int64) error
+		Reorder(context.Context, int64, []int64) error
+	}
+	Sessions interface {
+		Create(context.Context, *Session, string, time.Duration) error
+		Rotate(context.Context, string, string, string, time.Duration) (*Session, error)
+		RevokeByToken(context.Context, string) error
+		GetByUserID(context.Context, int64) ([]Session, error)
+		Touch(context.Context, int64, int64, string) error
+		Revoke(context.Context, int64, int64) error
+	}
+	TwoFactor interface {
+		GetByUserID(context.Context, int64) (*TwoFactor, error)
+		Enroll(context.Context, int64, string) error
+		Confirm(context.Context, int64, int64, []string) error
+		UseStep(context.Context, int64, int64) error
+		UseRecoveryCode(context.Context, int64, string) error
+		CountAttempt(context.Context, int64, int, time.Duration) error
+		ResetAttempts(context.Context, int64) error
+		CreateChallenge(context.Context, string, int64, time.Duration) error
+		UseChallenge(context.Context, string, int64) error
+		Disable(context.Context, int64) error
+	}
+	Identities interface {
+		GetUser(context.Context, string, string) (*User, error)
+		Link(context.Context, int64, string, string, string) error
+		CreateUser(context.Context, *User, string, string) error
+		CreateState(context.Context, *OAuthState, time.Duration) error
+		ConsumeState(context.Context, string) (*OAuthState, error)
+	}
+	LinkPreviews interface {
+		GetByURL(context.Context, string, time.Time) (*LinkPreview, error)
+		Save(context.Context, *LinkPreview) error
+		Attach(context.Context, int64, string) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
 	return Storage{
-		Posts:    &PostStorage{db},
-		Users:    &UserStorage{db},
-		Comments: &CommentStorage{db},
-		Roles:    &RoleStorage{db},
+		Posts:       &PostStorage{db},
+		Users:       &UserStorage{db},
+		Comments:    &CommentStorage{db},
+		Roles:       &RoleStorage{db},
+		Reactions:   &ReactionStorage{db},
+		Revisions:   &RevisionStorage{db},
+		Attachments: &AttachmentStorage{db},
+		Mentions:    &MentionStorage{db},
+		Tags:        &TagStorage{db},
+		Bookmarks:   &BookmarkStorage{db},
+		Reposts:     &RepostStorage{db},
+		Polls:       &PollStorage{db},
+		Pins:        &PinStorage{db},
+
+		LinkPreviews: &LinkPreviewStorage{db},
+		Sessions:     &SessionStorage{db},
+		TwoFactor:    &TwoFactorStorage{db},
+		Identities:   &IdentityStorage{db},
 	}
 }
 
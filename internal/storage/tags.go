@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TrendingTag struct {
+	Tag       string `json:"tag"`
+	PostCount int    `json:"post_count"`
+}
+
+type TagStorage struct {
+	db *sql.DB
+}
+
+// GetPosts lists the public posts carrying the tag, the tag containment check is served by the GIN index on posts.tags.
+func (t *TagStorage) GetPosts(ctx context.Context, tag string, pagQ PaginateQuery) ([]PostForFeed, error) {
+	query := `
+	SELECT
+		p.id, p.user_id, p.title, p.content, p.format, p.created_at, p.version, p.tags, p.status, p.publish_at, p.visibility, p.quoted_post_id,
+		u.username, COUNT(c.id) AS comments_count
+	FROM posts p
+	LEFT JOIN comments c ON c.post_id = p.id
+	LEFT JOIN users u ON p.user_id = u.id
+	WHERE p.tags @> ARRAY[$1]::varchar(100)[]
+	AND p.deleted_at IS NULL
+	AND ` + publicFilter + `
+	AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%')
+	AND (p.tags @> $5 OR array_length($5, 1) IS NULL)
+	GROUP BY p.id, u.username
+	ORDER BY p.created_at ` + pagQ.Sort + `
+	LIMIT $2 OFFSET $3;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := t.db.QueryContext(ctx, query, tag, pagQ.Limit, pagQ.Offset, pagQ.Search, pq.Array(pagQ.Tags))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	posts := []PostForFeed{}
+	for rows.Next() {
+		var p PostForFeed
+		if err := rows.Scan(
+			&p.Post.ID,
+			&p.Post.UserID,
+			&p.Post.Title,
+			&p.Post.Content,
+			&p.Post.Format,
+			&p.Post.CreatedAt,
+			&p.Post.Version,
+			pq.Array(&p.Post.Tags),
+			&p.Post.Status,
+			&p.Post.PublishAt,
+			&p.Post.Visibility,
+			&p.Post.QuotedPostID,
+			&p.Post.User.Username,
+			&p.CommentCount); err != nil {
+			return nil, err
+		}
+		p.Post.User.ID = p.Post.UserID
+		posts = append(posts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadFeedDetails(ctx, t.db, posts, 0); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GetTrending ranks the tags by the number of public posts created since the given time.
+func (t *TagStorage) GetTrending(ctx context.Context, since time.Time, limit int) ([]TrendingTag, error) {
+	query := `SELECT tag, COUNT(*) AS post_count
+		FROM posts p, unnest(p.tags) AS tag
+		WHERE p.created_at >= $1 AND p.deleted_at IS NULL AND ` + publicFilter + `
+		GROUP BY tag
+		ORDER BY post_count DESC, tag
+		LIMIT $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	rows, err := t.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []TrendingTag{}
+	for rows.Next() {
+		var tag TrendingTag
+		if err := rows.Scan(&tag.Tag, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TwoFactor is the TOTP secret of a user. It only guards the login once the user confirmed it with a code.
+type TwoFactor struct {
+	UserID   int64
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+type TwoFactorStorage struct {
+	db *sql.DB
+}
+
+func (s *TwoFactorStorage) GetByUserID(ctx context.Context, userID int64) (*TwoFactor, error) {
+	query := `SELECT user_id, secret, confirmed_at IS NOT NULL, last_step FROM user_totp WHERE user_id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var tf TwoFactor
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.LastStep)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &tf, nil
+}
+
+// Enroll stores a new unconfirmed secret, replacing an earlier unconfirmed one. It fails with ErrConflict when two
+// factor authentication is already enabled.
+func (s *TwoFactorStorage) Enroll(ctx context.Context, userID int64, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = NOW()
+		WHERE user_totp.confirmed_at IS NULL;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Confirm enables the pending secret, using up the step of the confirming code, and replaces the recovery codes
+// with the given hashes.
+func (s *TwoFactorStorage) Confirm(ctx context.Context, userID, step int64, codeHashes []string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE user_totp SET confirmed_at = NOW(), last_step = $2
+			WHERE user_id = $1 AND confirmed_at IS NULL;`
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if err := execAffected(ctx, tx, query, userID, step); err != nil {
+			return err
+		}
+		query = `DELETE FROM recovery_codes WHERE user_id = $1;`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+		query = `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2);`
+		for _, hash := range codeHashes {
+			if _, err := tx.ExecContext(ctx, query, userID, hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UseStep records the step of an accepted code. A step at or before the last used one fails with ErrCodeUsed, so
+// every code works once.
+func (s *TwoFactorStorage) UseStep(ctx context.Context, userID, step int64) error {
+	query := `UPDATE user_totp SET last_step = $2 WHERE user_id = $1 AND confirmed_at IS NOT NULL AND last_step < $2;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if err := execAffected(ctx, s.db, query, userID, step); err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return ErrCodeUsed
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode spends the recovery code, unknown and spent codes fail with ErrNotFound.
+func (s *TwoFactorStorage) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) error {
+	query := `UPDATE recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return execAffected(ctx, s.db, query, userID, codeHash)
+}
+
+// CountAttempt records an attempt to pass the second factor of the user before its code is checked. The attempt
+// that reaches max attempts since the last success locks the second factor for lockout, attempts while it is
+// locked fail with ErrLocked. The count starts over once a lock has passed.
+func (s *TwoFactorStorage) CountAttempt(ctx context.Context, userID int64, max int, lockout time.Duration) error {
+	query := `UPDATE user_totp SET
+			failed_attempts = CASE WHEN locked_until IS NULL THEN failed_attempts + 1 ELSE 1 END,
+			locked_until = CASE WHEN (CASE WHEN locked_until IS NULL THEN failed_attempts + 1 ELSE 1 END) >= $2
+				THEN NOW() + $3 * INTERVAL '1 second' END
+		WHERE user_id = $1 AND (locked_until IS NULL OR locked_until <= NOW());`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	if err := execAffected(ctx, s.db, query, userID, max, lockout.Seconds()); err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return ErrLocked
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetAttempts clears the attempts and the lock of the user after a passed second factor.
+func (s *TwoFactorStorage) ResetAttempts(ctx context.Context, userID int64) error {
+	query := `UPDATE user_totp SET failed_attempts = 0, locked_until = NULL WHERE user_id = $1;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// CreateChallenge stores the ID of a login challenge of the user for exp and drops the expired ones.
+func (s *TwoFactorStorage) CreateChallenge(ctx context.Context, challengeID string, userID int64, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM two_factor_challenges WHERE expires_at < NOW();`); err != nil {
+			return err
+		}
+		query := `INSERT INTO two_factor_challenges (id, user_id, expires_at) VALUES ($1, $2, $3);`
+		_, err := tx.ExecContext(ctx, query, challengeID, userID, time.Now().Add(exp))
+		return err
+	})
+}
+
+// UseChallenge removes the unexpired challenge of the user, so every challenge completes one login. Unknown, used
+// and expired challenges fail with ErrNotFound.
+func (s *TwoFactorStorage) UseChallenge(ctx context.Context, challengeID string, userID int64) error {
+	query := `DELETE FROM two_factor_challenges WHERE id = $1 AND user_id = $2 AND expires_at > NOW();`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	return execAffected(ctx, s.db, query, challengeID, userID)
+}
+
+// Disable removes the secret and the recovery codes of the user.
+func (s *TwoFactorStorage) Disable(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`, userID); err != nil {
+			return err
+		}
+		return execAffected(ctx, tx, `DELETE FROM user_totp WHERE user_id = $1;`, userID)
+	})
+}
+
+// execAffected runs the statement and fails with ErrNotFound when it changed no row.
+func execAffected(ctx context.Context, db execer, query string, args ...any) error {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rowsCount, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
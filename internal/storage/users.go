@@ -20,6 +20,19 @@ type User struct {
 	CreatedAt string   `json:"created_at"`
 	Is_Active bool     `json:"is_active"`
 	Role_id   int64    `json:"role_id"`
+
+	DisplayName       string     `json:"display_name"`
+	Bio               string     `json:"bio"`
+	Website           string     `json:"website"`
+	Location          string     `json:"location"`
+	AvatarURL         string     `json:"avatar_url"`
+	UsernameChangedAt *time.Time `json:"username_changed_at"`
+
+	// The counts and the relationship are only filled in on profile responses, see UserStats.
+	FollowerCount  *int          `json:"follower_count,omitempty"`
+	FollowingCount *int          `json:"following_count,omitempty"`
+	PostCount      *int          `json:"post_count,omitempty"`
+	Relationship   *Relationship `json:"relationship,omitempty"`
 }
 
 type password struct {
@@ -95,7 +108,9 @@ func (u *UserStorage) Delete(ctx context.Context, id int64) error {
 
 func (u *UserStorage) GetByID(ctx context.Context, userId int64) (*User, error) {
 	var user User
-	query := `SELECT id, username, email, password, created_at, is_active, role_id FROM users WHERE id = $1 AND is_active = TRUE;`
+	query := `SELECT id, username, email, password, created_at, is_active, role_id,
+			display_name, bio, website, location, avatar_url, username_changed_at
+		FROM users WHERE id = $1 AND is_active = TRUE;`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 	err := u.db.QueryRowContext(ctx, query, userId).Scan(
@@ -105,7 +120,13 @@ func (u *UserStorage) GetByID(ctx context.Context, userId int64) (*User, error)
 		&user.Password.hash,
 		&user.CreatedAt,
 		&user.Is_Active,
-		&user.Role_id)
+		&user.Role_id,
+		&user.DisplayName,
+		&user.Bio,
+		&user.Website,
+		&user.Location,
+		&user.AvatarURL,
+		&user.UsernameChangedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -119,6 +140,50 @@ func (u *UserStorage) GetByID(ctx context.Context, userId int64) (*User, error)
 	return &user, nil
 }
 
+// UpdateProfile saves the profile fields and the username of the user, a changed username records the time of the
+// change. A username taken by another user, in any case, fails with ErrConflict. Changing the username again
+// within the cooldown fails with ErrUsernameCooldown and sets user.UsernameChangedAt to the last change.
+func (u *UserStorage) UpdateProfile(ctx context.Context, user *User, cooldown time.Duration) error {
+	query := `UPDATE users
+		SET username_changed_at = CASE WHEN username <> $1 THEN NOW() ELSE username_changed_at END,
+			username = $1, display_name = $2, bio = $3, website = $4, location = $5, avatar_url = $6
+		WHERE id = $7 AND is_active = TRUE
+		AND (username = $1 OR username_changed_at IS NULL OR username_changed_at <= NOW() - $8 * INTERVAL '1 second')
+		RETURNING username_changed_at;`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+	err := u.db.QueryRowContext(ctx, query, user.Username, user.DisplayName, user.Bio, user.Website, user.Location,
+		user.AvatarURL, user.ID, cooldown.Seconds()).Scan(&user.UsernameChangedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrConflict
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return u.usernameCooldown(ctx, user)
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// usernameCooldown tells why UpdateProfile changed no row: the user is gone or the cooldown is not over.
+func (u *UserStorage) usernameCooldown(ctx context.Context, user *User) error {
+	query := `SELECT COALESCE(username_changed_at, NOW()) FROM users WHERE id = $1 AND is_active = TRUE;`
+	var changedAt time.Time
+	if err := u.db.QueryRowContext(ctx, query, user.ID).Scan(&changedAt); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+	user.UsernameChangedAt = &changedAt
+	return ErrUsernameCooldown
+}
+
 func (u *UserStorage) GetByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
 	query := `SELECT id, username, email, password, created_at, is_active FROM users WHERE email = $1 AND is_active = TRUE;`
@@ -186,25 +251,45 @@ func (u *UserStorage) UnFollow(ctx context.Context, fId int64, userId int64) err
 
 }
 
+// GetUserFeed lists the posts of the user and the users they follow together with the posts those users
+// reposted. A post shows up once: as an original when its author is in the feed, otherwise attributed to its
+// most recent repost.
 func (u *UserStorage) GetUserFeed(ctx context.Context, user_id int64, pagQ PaginateQuery) ([]PostForFeed, error) {
 	query := `
+	WITH entries AS (
+		SELECT p.id AS post_id, NULL::bigint AS reposter_id, p.created_at AS shared_at
+		FROM posts p
+		LEFT JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
+		WHERE p.user_id = $1 OR f.user_id IS NOT NULL
+		UNION ALL
+		SELECT r.post_id, r.user_id, r.created_at
+		FROM reposts r
+		LEFT JOIN followers f ON f.user_id = r.user_id AND f.follower_id = $1
+		WHERE r.user_id = $1 OR f.user_id IS NOT NULL
+	), feed AS (
+		SELECT DISTINCT ON (post_id) post_id, reposter_id, shared_at
+		FROM entries
+		ORDER BY post_id, reposter_id IS NOT NULL, shared_at DESC
+	)
 	SELECT 
-    	p.id, p.user_id, p.title, p.content, p.created_at, p.version, p.tags,
-    	u.username, COUNT(c.id) AS comments_count
-	FROM posts p
+    	p.id, p.user_id, p.title, p.content, p.format, p.created_at, p.version, p.tags, p.status, p.publish_at, p.visibility,
+    	p.quoted_post_id, u.username, COUNT(c.id) AS comments_count, e.reposter_id, ru.username
+	FROM feed e
+	JOIN posts p ON p.id = e.post_id
 	LEFT JOIN comments c ON c.post_id = p.id
 	LEFT JOIN users u ON p.user_id = u.id
-	LEFT JOIN followers f ON f.user_id = p.user_id AND f.follower_id = $1
-	WHERE (p.user_id = $1 OR f.user_id IS NOT NULL) 
+	LEFT JOIN users ru ON ru.id = e.reposter_id
+	WHERE p.deleted_at IS NULL
+    AND ` + visibleTo("$1") + `
     AND (p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') 
-    AND (p.tags @> $5 OR array_length($5, 1) = 0) 
-	GROUP BY p.id, u.username
-	ORDER BY p.created_at ` + pagQ.Sort + `
+    AND (p.tags @> $5 OR array_length($5, 1) IS NULL) 
+	GROUP BY p.id, u.username, e.reposter_id, ru.username, e.shared_at
+	ORDER BY e.shared_at ` + pagQ.Sort + `
 	LIMIT $2 OFFSET $3;
 `
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
-	rows, err := u.db.QueryContext(ctx, query, user_id, pagQ.Limit, pagQ.Offset, pagQ.Search, pagQ.Tags)
+	rows, err := u.db.QueryContext(ctx, query, user_id, pagQ.Limit, pagQ.Offset, pagQ.Search, pq.Array(pagQ.Tags))
 	if err != nil {
 		return nil, err
 	}
@@ -212,22 +297,41 @@ func (u *UserStorage) GetUserFeed(ctx context.Context, user_id int64, pagQ Pagin
 	var feed []PostForFeed
 	for rows.Next() {
 		var p PostForFeed
+		var reposterID sql.NullInt64
+		var reposterName sql.NullString
 		err := rows.Scan(
 			&p.Post.ID,
 			&p.Post.UserID,
 			&p.Post.Title,
 			&p.Post.Content,
+			&p.Post.Format,
 			&p.Post.CreatedAt,
 			&p.Post.Version,
 			pq.Array(&p.Post.Tags),
+			&p.Post.Status,
+			&p.Post.PublishAt,
+			&p.Post.Visibility,
+			&p.Post.QuotedPostID,
 			&p.Post.User.Username,
-			&p.CommentCount)
+			&p.CommentCount,
+			&reposterID,
+			&reposterName)
 		if err != nil {
 			return nil, err
 		}
+		if reposterID.Valid {
+			p.RepostedBy = &User{ID: reposterID.Int64, Username: reposterName.String}
+		}
 		feed = append(feed, p)
 
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadFeedDetails(ctx, u.db, feed, user_id); err != nil {
+		return nil, err
+	}
 
 	return feed, nil
 }
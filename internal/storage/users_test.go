@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateProfileUsername(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	s := NewStorage(db)
+	users := createTestUsers(t, db, "renamer", "other")
+	renamer, other := users[0], users[1]
+	db.ExecContext(ctx, `UPDATE users SET is_active = TRUE WHERE id = ANY(ARRAY[$1, $2]::bigint[])`, renamer.ID, other.ID)
+
+	renamer.Username = strings.ToUpper(other.Username)
+	if err := s.Users.UpdateProfile(ctx, renamer, time.Hour); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected %v for a username taken in another case, but got %v", ErrConflict, err)
+	}
+
+	renamer.Username += "_x"
+	if err := s.Users.UpdateProfile(ctx, renamer, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	renamer.Username += "_y"
+	if err := s.Users.UpdateProfile(ctx, renamer, time.Hour); !errors.Is(err, ErrUsernameCooldown) {
+		t.Errorf("Expected %v within the cooldown, but got %v", ErrUsernameCooldown, err)
+	}
+	if renamer.UsernameChangedAt == nil {
+		t.Errorf("Expected the time of the last change")
+	}
+	if err := s.Users.UpdateProfile(ctx, renamer, 0); err != nil {
+		t.Errorf("Expected the change after the cooldown, but got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		code, err := Code(rfcSecret, Step(time.Unix(c.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != c.code {
+			t.Errorf("Expected %s at %d, but got %s", c.code, c.unix, code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	previous, _ := Code(rfcSecret, Step(now)-1)
+	if step, ok := Validate(rfcSecret, previous, now); !ok || step != Step(now)-1 {
+		t.Errorf("Expected the previous step to match, but got %d %v", step, ok)
+	}
+	old, _ := Code(rfcSecret, Step(now)-2)
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Error("Expected a code two steps old to be refused")
+	}
+	if _, ok := Validate(rfcSecret, "12345", now); ok {
+		t.Error("Expected a short code to be refused")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Gopher Social", "gopher@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/Gopher%20Social:gopher@example.com?") ||
+		!strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") || !strings.Contains(uri, "issuer=Gopher+Social") {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Code(secret, 1); err != nil || len(secret) != 32 {
+		t.Errorf("Expected a usable 32 character secret, but got %q: %v", secret, err)
+	}
+}
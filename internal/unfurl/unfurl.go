@@ -0,0 +1,267 @@
+// Package unfurl fetches the OpenGraph and Twitter card metadata of a web page to show a link preview.
+//
+// Pages are fetched with a strict timeout and body size cap. Unless private networks are allowed, connections to
+// loopback, private, link-local and other non public addresses are refused after DNS resolution, including on
+// redirects, so links cannot be used to probe the internal network.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrInvalidURL     = errors.New("only absolute http and https URLs can be unfurled")
+	ErrBlockedAddress = errors.New("the address is not publicly routable")
+	ErrNotHTML        = errors.New("the page is not an HTML document")
+	ErrNoMetadata     = errors.New("the page has no preview metadata")
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+type Config struct {
+	Timeout      time.Duration
+	MaxBodySize  int64
+	MaxRedirects int
+	UserAgent    string
+	// AllowPrivateNetworks turns the address checks off, it is meant for tests against a local server.
+	AllowPrivateNetworks bool
+}
+
+// Preview is the card of a page, ImageURL is absolute.
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+type Unfurler struct {
+	cfg    Config
+	client *http.Client
+}
+
+func New(cfg Config) *Unfurler {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = checkAddress
+	}
+	transport := &http.Transport{
+		// No proxy, the dialer has to see the address of the page to check it.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Minute,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}
+	return &Unfurler{cfg: cfg, client: client}
+}
+
+// Fetch downloads the page at rawURL and extracts its preview, OpenGraph tags win over Twitter card tags which
+// win over the page title and description.
+func (u *Unfurler) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	if u.cfg.UserAgent != "" {
+		req.Header.Set("User-Agent", u.cfg.UserAgent)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return nil, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, u.cfg.MaxBodySize), contentType)
+	if err != nil {
+		return nil, err
+	}
+	preview := parse(body, resp.Request.URL)
+	if preview.Title == "" && preview.Description == "" && preview.ImageURL == "" {
+		return nil, ErrNoMetadata
+	}
+	preview.URL = rawURL
+	return preview, nil
+}
+
+// parse reads the head of the document, a document cut short by the size cap still yields what was read.
+func parse(r io.Reader, base *url.URL) *Preview {
+	meta := map[string]string{}
+	var title string
+	inTitle := false
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "body":
+				break loop
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				if !hasAttr {
+					continue
+				}
+				var key, content string
+				for {
+					attr, val, more := z.TagAttr()
+					switch string(attr) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(string(val)))
+					case "content":
+						content = strings.TrimSpace(string(val))
+					}
+					if !more {
+						break
+					}
+				}
+				if _, ok := meta[key]; key != "" && content != "" && !ok {
+					meta[key] = content
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "head":
+				break loop
+			case "title":
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(string(z.Text()))
+			}
+		}
+	}
+
+	preview := &Preview{
+		Title:       truncate(first(meta["og:title"], meta["twitter:title"], title), maxTitleLength),
+		Description: truncate(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		SiteName:    truncate(meta["og:site_name"], maxTitleLength),
+	}
+	image := first(meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"])
+	if ref, err := url.Parse(image); image != "" && err == nil {
+		abs := base.ResolveReference(ref)
+		if abs.Scheme == "http" || abs.Scheme == "https" {
+			preview.ImageURL = abs.String()
+		}
+	}
+	return preview
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// truncate cuts s to at most max characters.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+// checkAddress is the dialer's Control hook, it runs with the resolved address right before connecting.
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublic(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// nonPublic lists the special purpose ranges the net.IP predicates do not cover.
+var nonPublic = func() []*net.IPNet {
+	cidrs := []string{
+		"0.0.0.0/8",
+		"100.64.0.0/10",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"240.0.0.0/4",
+		"64:ff9b::/96",
+		"2001:db8::/32",
+	}
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, nets[i], _ = net.ParseCIDR(cidr)
+	}
+	return nets
+}()
+
+func isPublic(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, n := range nonPublic {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
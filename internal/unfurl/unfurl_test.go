@@ -0,0 +1,157 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestUnfurler(allowPrivate bool) *Unfurler {
+	return New(Config{
+		Timeout:              time.Second,
+		MaxBodySize:          1 << 10,
+		MaxRedirects:         2,
+		AllowPrivateNetworks: allowPrivate,
+	})
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/og", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head>
+			<title>Fallback</title>
+			<meta property="og:title" content="Open &amp; Graph">
+			<meta name="twitter:title" content="Twitter">
+			<meta name="description" content="Plain description">
+			<meta property="og:image" content="/img/card.png">
+			<meta property="og:site_name" content="Example">
+			</head><body><meta property="og:description" content="too late"></body></html>`))
+	})
+	mux.HandleFunc("/twitter", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><meta name="twitter:title" content="Card"><meta name="twitter:image" content="javascript:alert(1)"></head>`))
+	})
+	mux.HandleFunc("/title", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<title> Just a title </title>`))
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<p>nothing here</p>`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><!--` + strings.Repeat("x", 4<<10) + `--><title>Hidden</title></head>`))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+		}
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/redirect", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u := newTestUnfurler(true)
+	ctx := context.Background()
+
+	t.Run("should prefer OpenGraph metadata", func(t *testing.T) {
+		preview, err := u.Fetch(ctx, srv.URL+"/og")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Preview{
+			URL:         srv.URL + "/og",
+			Title:       "Open & Graph",
+			Description: "Plain description",
+			ImageURL:    srv.URL + "/img/card.png",
+			SiteName:    "Example",
+		}
+		if *preview != want {
+			t.Errorf("Expected %+v, but got %+v", want, *preview)
+		}
+	})
+	t.Run("should fall back to Twitter card metadata", func(t *testing.T) {
+		preview, err := u.Fetch(ctx, srv.URL+"/twitter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if preview.Title != "Card" || preview.ImageURL != "" {
+			t.Errorf("Expected the card title without the unsafe image, but got %+v", *preview)
+		}
+	})
+	t.Run("should fall back to the page title", func(t *testing.T) {
+		preview, err := u.Fetch(ctx, srv.URL+"/title")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if preview.Title != "Just a title" {
+			t.Errorf("Expected the page title, but got %+v", *preview)
+		}
+	})
+	t.Run("should fail on pages without a preview", func(t *testing.T) {
+		for path, want := range map[string]error{
+			"/json":  ErrNotHTML,
+			"/empty": ErrNoMetadata,
+			"/big":   ErrNoMetadata,
+		} {
+			if _, err := u.Fetch(ctx, srv.URL+path); !errors.Is(err, want) {
+				t.Errorf("%s: expected %v, but got %v", path, want, err)
+			}
+		}
+	})
+	t.Run("should give up on slow pages and redirect loops", func(t *testing.T) {
+		for _, path := range []string{"/slow", "/redirect", "/missing"} {
+			if _, err := u.Fetch(ctx, srv.URL+path); err == nil {
+				t.Errorf("%s: expected an error", path)
+			}
+		}
+	})
+	t.Run("should refuse non http URLs", func(t *testing.T) {
+		for _, rawURL := range []string{"file:///etc/passwd", "/relative", "gopher://example.com"} {
+			if _, err := u.Fetch(ctx, rawURL); !errors.Is(err, ErrInvalidURL) {
+				t.Errorf("%s: expected %v, but got %v", rawURL, ErrInvalidURL, err)
+			}
+		}
+	})
+	t.Run("should refuse private addresses", func(t *testing.T) {
+		if _, err := newTestUnfurler(false).Fetch(ctx, srv.URL+"/og"); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected %v, but got %v", ErrBlockedAddress, err)
+		}
+	})
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::1":              false,
+		"fc00::1":          false,
+		"fe80::1":          false,
+		"::ffff:127.0.0.1": false,
+	} {
+		if got := isPublic(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublic(%s): expected %v, but got %v", addr, want, got)
+		}
+	}
+}